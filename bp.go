@@ -0,0 +1,249 @@
+package main
+
+import "sort"
+
+// Backpatcher is the one-pass generator of Section 6.7 of the Dragon
+// Book. Instead of passing the labels b, a, t and f down into gen and
+// jumping, it emits each jump with its target left open and returns the
+// open jumps in a truelist, falselist or nextlist, which are backpatched
+// once the target instruction is known. Only the instructions that are
+// jumped to get a label, so the code needs far fewer labels.
+//
+// Straight-line code still comes from reduce. The Backpatcher is the
+// Emitter behind it, and resolves the labels that reduce and the bounds
+// checks use when the code is finished.
+type Backpatcher struct {
+	Program
+	target map[int]int // jump -> index of the instruction it goes to
+	jumps  map[int]int // jump -> label it goes to
+	labels map[int]int // label -> index of the instruction it marks
+	breaks map[Node][]int
+}
+
+// Backpatch generates the code of the program s.
+func Backpatch(s Node) *Program {
+	b := &Backpatcher{
+		target: map[int]int{},
+		jumps:  map[int]int{},
+		labels: map[int]int{},
+		breaks: map[Node][]int{},
+	}
+	b.backpatch(b.stmt(s), b.here())
+	emitBoundsHandler(b)
+	return b.finish()
+}
+
+// here is the index of the next instruction.
+func (b *Backpatcher) here() int { return len(b.code) }
+
+// jump emits q with an open target and returns its index.
+func (b *Backpatcher) jump(q Quad) int {
+	b.add(q)
+	return len(b.code) - 1
+}
+
+func (b *Backpatcher) backpatch(list []int, i int) {
+	for _, j := range list {
+		b.target[j] = i
+	}
+}
+
+func merge(lists ...[]int) []int {
+	var m []int
+	for _, l := range lists {
+		m = append(m, l...)
+	}
+	return m
+}
+
+// stmt generates s and returns its nextlist, the jumps that leave it.
+func (b *Backpatcher) stmt(n Node) []int {
+	switch s := n.(type) {
+	case nil:
+		return nil
+	case Seq:
+		b.backpatch(b.stmt(s.stmt1), b.here())
+		return b.stmt(s.stmt2)
+	case If:
+		t, f := b.cond(s.expr)
+		b.backpatch(t, b.here())
+		return merge(f, b.stmt(s.stmt))
+	case Else:
+		t, f := b.cond(s.expr)
+		b.backpatch(t, b.here())
+		next1 := b.stmt(s.stmt1)
+		g := b.jump(Quad{op: OpGoto})
+		b.backpatch(f, b.here())
+		return merge(next1, []int{g}, b.stmt(s.stmt2))
+	case *While:
+		begin := b.here()
+		t, f := b.cond(s.expr)
+		b.backpatch(t, b.here())
+		b.backpatch(b.stmt(s.stmt), begin)
+		b.backpatch([]int{b.jump(Quad{op: OpGoto})}, begin)
+		return merge(f, b.breaks[s])
+	case *Do:
+		begin := b.here()
+		b.backpatch(b.stmt(s.stmt), b.here())
+		t, f := b.cond(s.expr)
+		b.backpatch(t, begin)
+		return merge(f, b.breaks[s])
+	case Break:
+		b.breaks[s.stmt] = append(b.breaks[s.stmt], b.jump(Quad{op: OpGoto}))
+		return nil
+	case Set:
+		switch s.expr.(type) {
+		case Rel, OrNode, AndNode, Not:
+			t, f := b.cond(s.expr)
+			b.backpatch(t, b.here())
+			b.Assign(s.id, ConstantTrue)
+			g := b.jump(Quad{op: OpGoto})
+			b.backpatch(f, b.here())
+			b.Assign(s.id, ConstantFalse)
+			return []int{g}
+		}
+	}
+	n.gen(b, 0, 0)
+	return nil
+}
+
+// cond generates the jumping code of the boolean x and returns its
+// truelist and falselist.
+func (b *Backpatcher) cond(n Node) (t, f []int) {
+	switch x := n.(type) {
+	case OrNode:
+		t1, f1 := b.cond(x.expr1)
+		b.backpatch(f1, b.here())
+		t2, f2 := b.cond(x.expr2)
+		return merge(t1, t2), f2
+	case AndNode:
+		t1, f1 := b.cond(x.expr1)
+		b.backpatch(t1, b.here())
+		t2, f2 := b.cond(x.expr2)
+		return t2, merge(f1, f2)
+	case Not:
+		t, f := b.cond(x.expr2)
+		return f, t
+	case Constant:
+		if x == ConstantTrue {
+			return []int{b.jump(Quad{op: OpGoto})}, nil
+		} else if x == ConstantFalse {
+			return nil, []int{b.jump(Quad{op: OpGoto})}
+		}
+	case Rel:
+		return b.test(x.test(b))
+	}
+	return b.test(n.reduce(b))
+}
+
+// test emits
+//
+//	if test goto _
+//	goto _
+//
+// and returns the two jumps. finish turns the pair into one iffalse when
+// the true exit falls through.
+func (b *Backpatcher) test(test Node) (t, f []int) {
+	q := condition(test)
+	q.op = OpIf
+	t = []int{b.jump(q)}
+	f = []int{b.jump(Quad{op: OpGoto})}
+	return t, f
+}
+
+// Label, Goto and CondJump make the Backpatcher the Emitter of the code
+// that reduce generates. They refer to the labels of newLabel.
+
+func (b *Backpatcher) Label(i int) { b.labels[i] = b.here() }
+
+func (b *Backpatcher) Goto(i int) {
+	b.jumps[b.jump(Quad{op: OpGoto})] = i
+}
+
+func (b *Backpatcher) CondJump(test Node, t, f int) {
+	q := condition(test)
+	if t != 0 {
+		q.op = OpIf
+		b.jumps[b.jump(q)] = t
+		if f != 0 {
+			b.Goto(f)
+		}
+	} else if f != 0 {
+		q.op = OpIfFalse
+		b.jumps[b.jump(q)] = f
+	}
+}
+
+// finish resolves the jumps to labels, folds a conditional jump over an
+// unconditional one into a single jump, drops jumps to the next
+// instruction and labels the instructions that are still jumped to.
+func (b *Backpatcher) finish() *Program {
+	for j, l := range b.jumps {
+		b.target[j] = b.labels[l]
+	}
+	jumpedTo := map[int]bool{}
+	for _, i := range b.target {
+		jumpedTo[i] = true
+	}
+	dead := make([]bool, len(b.code)+1)
+	for i := 0; i < len(b.code); i++ {
+		q := &b.code[i]
+		if (q.op == OpIf || q.op == OpIfFalse) && b.target[i] == i+2 &&
+			b.code[i+1].op == OpGoto && !jumpedTo[i+1] {
+			if q.op == OpIf {
+				q.op = OpIfFalse
+			} else {
+				q.op = OpIf
+			}
+			b.target[i] = b.target[i+1]
+			dead[i+1] = true
+			i++
+		} else if q.op == OpGoto && b.target[i] == i+1 {
+			dead[i] = true
+		}
+	}
+
+	// index maps the old index of an instruction to the new one; a dead
+	// instruction maps to the one that follows it.
+	index := make([]int, len(b.code)+1)
+	n := 0
+	for i := range index {
+		index[i] = n
+		if i < len(b.code) && !dead[i] {
+			n++
+		}
+	}
+	var targets []int
+	label := map[int]int{}
+	for j, i := range b.target {
+		if dead[j] {
+			continue
+		}
+		if _, ok := label[index[i]]; !ok {
+			label[index[i]] = 0
+			targets = append(targets, index[i])
+		}
+	}
+	sort.Ints(targets)
+	for _, i := range targets {
+		label[i] = newLabel()
+	}
+
+	code := &Program{}
+	for i, q := range b.code {
+		if dead[i] {
+			continue
+		}
+		if l, ok := label[index[i]]; ok {
+			code.Label(l)
+		}
+		if q.op == OpGoto || q.op == OpIf || q.op == OpIfFalse {
+			q.result = LabelOf(label[index[b.target[i]]])
+		}
+		code.add(q)
+	}
+	if l, ok := label[n]; ok {
+		code.Label(l)
+	}
+	return code
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Block is a basic block: a run of quads that is entered only at its
+// first quad and left only after its last. labels are the labels that
+// mark its start.
+type Block struct {
+	id         int
+	labels     []int
+	code       []Quad
+	pred, succ []*Block
+}
+
+func (b *Block) String() string { return fmt.Sprintf("B%d", b.id) }
+
+// CFG is the control flow graph of a program. blocks holds the entry, the
+// basic blocks in program order and the exit, which are empty.
+type CFG struct {
+	blocks      []*Block
+	entry, exit *Block
+}
+
+func isJump(op Opcode) bool { return op == OpGoto || op == OpIf || op == OpIfFalse }
+
+// NewCFG partitions p into basic blocks with the leader algorithm: the
+// first quad, every quad that is jumped to and every quad that follows a
+// jump start a block. Labels that nothing jumps to are dropped. A block
+// ending in goto has one successor, one ending in if or iffalse also
+// falls through to the next block, and any other block only falls
+// through.
+func NewCFG(p *Program) *CFG {
+	target := map[int]bool{}
+	for _, q := range p.code {
+		if isJump(q.op) {
+			target[q.result.num] = true
+		}
+	}
+	g := &CFG{entry: &Block{}}
+	g.blocks = append(g.blocks, g.entry)
+	var b *Block
+	leader := true
+	for _, q := range p.code {
+		if q.op == OpLabel && !target[q.result.num] {
+			continue
+		}
+		if q.op == OpLabel {
+			if b == nil || len(b.code) > 0 {
+				leader = true
+			}
+		}
+		if leader {
+			b = &Block{id: len(g.blocks)}
+			g.blocks = append(g.blocks, b)
+			leader = false
+		}
+		if q.op == OpLabel {
+			b.labels = append(b.labels, q.result.num)
+			continue
+		}
+		b.code = append(b.code, q)
+		leader = isJump(q.op)
+	}
+	g.exit = &Block{id: len(g.blocks)}
+	g.blocks = append(g.blocks, g.exit)
+
+	labeled := map[int]*Block{}
+	for _, b := range g.blocks {
+		for _, l := range b.labels {
+			labeled[l] = b
+		}
+	}
+	g.edge(g.entry, g.blocks[1])
+	for i, b := range g.blocks[1 : len(g.blocks)-1] {
+		next := g.blocks[i+2]
+		if len(b.code) == 0 {
+			g.edge(b, next)
+			continue
+		}
+		last := b.code[len(b.code)-1]
+		if isJump(last.op) {
+			g.edge(b, labeled[last.result.num])
+		}
+		if last.op != OpGoto {
+			g.edge(b, next)
+		}
+	}
+	return g
+}
+
+// reachable returns the blocks that some path from the entry reaches.
+func (g *CFG) reachable() map[*Block]bool {
+	seen := map[*Block]bool{g.entry: true}
+	work := []*Block{g.entry}
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, s := range b.succ {
+			if !seen[s] {
+				seen[s] = true
+				work = append(work, s)
+			}
+		}
+	}
+	return seen
+}
+
+func (g *CFG) edge(from, to *Block) {
+	for _, s := range from.succ {
+		if s == to {
+			return
+		}
+	}
+	from.succ = append(from.succ, to)
+	to.pred = append(to.pred, from)
+}
+
+func (g *CFG) name(b *Block) string {
+	switch b {
+	case g.entry:
+		return "entry"
+	case g.exit:
+		return "exit"
+	}
+	return b.String()
+}
+
+func (g *CFG) names(list []*Block) string {
+	s := make([]string, len(list))
+	for i, b := range list {
+		s[i] = g.name(b)
+	}
+	return strings.Join(s, " ")
+}
+
+// program lays the blocks out again as a program, in order.
+func (g *CFG) program() *Program {
+	p := &Program{}
+	for _, b := range g.blocks {
+		for _, l := range b.labels {
+			p.add(Quad{op: OpLabel, result: LabelOf(l)})
+		}
+		p.code = append(p.code, b.code...)
+	}
+	return p
+}
+
+// print writes the blocks with their labels, edges and code:
+//
+//	B2 L3:	pred B1 B4	succ B3 B5
+//		t1 = i * 8
+func (g *CFG) print(w io.Writer) {
+	for _, b := range g.blocks {
+		g.header(w, b)
+		for _, q := range b.code {
+			fmt.Fprintf(w, "\t%s\n", q)
+		}
+	}
+}
+
+// header writes the line that starts b in print.
+func (g *CFG) header(w io.Writer, b *Block) {
+	fmt.Fprint(w, g.name(b))
+	for _, l := range b.labels {
+		fmt.Fprintf(w, " L%d:", l)
+	}
+	if len(b.pred) > 0 {
+		fmt.Fprintf(w, "\tpred %s", g.names(b.pred))
+	}
+	if len(b.succ) > 0 {
+		fmt.Fprintf(w, "\tsucc %s", g.names(b.succ))
+	}
+	fmt.Fprintln(w)
+}
+
+// dot writes g in the Graphviz format, one box per block.
+func (g *CFG) dot(w io.Writer) {
+	fmt.Fprintln(w, "digraph cfg {")
+	fmt.Fprintln(w, "\tnode [shape=box fontname=monospace]")
+	for _, b := range g.blocks {
+		var label strings.Builder
+		label.WriteString(g.name(b))
+		for _, l := range b.labels {
+			fmt.Fprintf(&label, " L%d:", l)
+		}
+		label.WriteString(`\l`)
+		for _, q := range b.code {
+			label.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(q.String()))
+			label.WriteString(`\l`)
+		}
+		fmt.Fprintf(w, "\t%s [label=\"%s\"]\n", g.name(b), label.String())
+	}
+	for _, b := range g.blocks {
+		for _, s := range b.succ {
+			fmt.Fprintf(w, "\t%s -> %s\n", g.name(b), g.name(s))
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
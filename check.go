@@ -0,0 +1,262 @@
+package main
+
+import "fmt"
+
+// Checker is the semantic analysis pass. It runs over the finished syntax
+// tree, resolves the type of every expression bottom up, stores it in the
+// node and reports each type error together with the types involved.
+// Identifiers are already bound to their Symbol by the parser, which is
+// the only place that knows the scopes.
+//
+// Errors go through Line.errorf, so one run reports all of them. An
+// operand whose type could not be resolved has been reported before and
+// is not reported again by the nodes above it.
+type Checker struct{}
+
+// resolved reports whether all the types are known.
+func resolved(types ...Typer) bool {
+	for _, t := range types {
+		if t == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Checker) stmt(n Node) Node {
+	switch s := n.(type) {
+	case Seq:
+		if s.stmt1 != nil {
+			s.stmt1 = c.stmt(s.stmt1)
+		}
+		if s.stmt2 != nil {
+			s.stmt2 = c.stmt(s.stmt2)
+		}
+		return s
+	case If:
+		s.expr = c.cond(s.Line, s.expr, "if")
+		if s.stmt != nil {
+			s.stmt = c.stmt(s.stmt)
+		}
+		return s
+	case Else:
+		s.expr = c.cond(s.Line, s.expr, "if")
+		if s.stmt1 != nil {
+			s.stmt1 = c.stmt(s.stmt1)
+		}
+		if s.stmt2 != nil {
+			s.stmt2 = c.stmt(s.stmt2)
+		}
+		return s
+	case *While:
+		s.expr = c.cond(s.Line, s.expr, "while")
+		if s.stmt != nil {
+			s.stmt = c.stmt(s.stmt)
+		}
+		return s
+	case *Do:
+		if s.stmt != nil {
+			s.stmt = c.stmt(s.stmt)
+		}
+		s.expr = c.cond(s.Line, s.expr, "do")
+		return s
+	case Set:
+		s.id = c.expr(s.id).(Id)
+		s.expr = c.expr(s.expr)
+		s.typ = s.check(s.id.typ, s.expr.typer())
+		c.assign(s.Line, s.typ, s.id.typ, s.expr)
+		return s
+	case SetElem:
+		s.index = c.index(s.Line, s.index)
+		s.expr = c.expr(s.expr)
+		c.assign(s.Line, s.check(s.typ, s.expr.typer()), s.typ, s.expr)
+		return s
+	case SetDeref:
+		s.addr = c.expr(s.addr)
+		s.expr = c.expr(s.expr)
+		s.typ = c.deref(s.Line, s.addr.typer())
+		if s.typ != nil {
+			c.assign(s.Line, s.check(s.typ, s.expr.typer()), s.typ, s.expr)
+		}
+		return s
+	case ArrayCopy:
+		s.dst = c.expr(s.dst)
+		s.src = c.expr(s.src)
+		t1, t2 := s.dst.typer(), s.src.typer()
+		if s.check(t1, t2) == nil {
+			if resolved(t1, t2) {
+				s.errorf("type error: cannot assign %s to %s", t2, t1)
+			}
+		} else if _, _, ok := s.row(s.src); !ok {
+			s.errorf("cannot copy array %s", s.src)
+		}
+		return s
+	case Print:
+		args := make([]Node, len(s.args))
+		for i, x := range s.args {
+			args[i] = c.expr(x)
+			if t := args[i].typer(); !IsPrintable(t) && resolved(t) {
+				s.errorf("type error: cannot print %s", t)
+			}
+		}
+		s.args = args
+		return s
+	}
+	return n
+}
+
+// assign reports an assignment of x to a to location that check
+// rejected, and warns when an accepted one narrows, unless x is an
+// integer constant that to can hold.
+func (c Checker) assign(l Line, checked, to Typer, x Node) {
+	from := x.typer()
+	if checked == nil {
+		if resolved(to, from) {
+			l.errorf("type error: cannot assign %s to %s", from, to)
+		}
+	} else if k, ok := x.(Constant); ok && IsIntegerType(from) && IsIntegerType(to) && fits(k, to) {
+		return
+	} else if IsNarrowing(from, to) {
+		l.warn(fmt.Sprintf("narrowing conversion from %s to %s requires a cast", from, to))
+	}
+}
+
+// fits reports whether the integer type t can hold the constant k.
+func fits(k Constant, t Typer) bool {
+	i, _ := value(k)
+	return wrap(t, i) == i
+}
+
+func (c Checker) cond(l Line, n Node, stmt string) Node {
+	x := c.expr(n)
+	if t := x.typer(); t != Bool && resolved(t) {
+		l.errorf("type error: boolean required in %s, got %s", stmt, t)
+	}
+	return x
+}
+
+// index checks the offset computed from the indexes of an array, which
+// is an integer only if every index is one.
+func (c Checker) index(l Line, n Node) Node {
+	x := c.expr(n)
+	if t := x.typer(); !IsIntegerType(t) && resolved(t) {
+		l.errorf("type error: array index must be an integer, got %s", t)
+	}
+	return x
+}
+
+func (c Checker) deref(l Line, t Typer) Typer {
+	if p, ok := t.(Pointer); ok {
+		return p.elem
+	} else if resolved(t) {
+		l.errorf("type error: dereference of non-pointer %s", t)
+	}
+	return nil
+}
+
+func (c Checker) expr(n Node) Node {
+	switch x := n.(type) {
+	case Id:
+		x.typ = x.sym.typ
+		return x
+	case Access:
+		x.index = c.index(x.Line, x.index)
+		return x
+	case Bound:
+		x.index = c.expr(x.index)
+		x.typ = x.index.typer()
+		return x
+	case Arith:
+		x.expr1 = c.expr(x.expr1)
+		x.expr2 = c.expr(x.expr2)
+		if IsPointerType(x.typ) { // address arithmetic built by the parser
+			return x
+		}
+		t1, t2 := x.expr1.typer(), x.expr2.typer()
+		x.typ = MaxType(t1, t2)
+		if x.typ == nil && resolved(t1, t2) {
+			x.errorf("type error: %s %s %s", t1, x.op, t2)
+		}
+		return x
+	case Unary:
+		x.expr = c.expr(x.expr)
+		t := x.expr.typer()
+		x.typ = MaxType(Int, t)
+		if x.typ == nil && resolved(t) {
+			x.errorf("type error: %s %s", x.op, t)
+		}
+		return x
+	case Cast:
+		x.expr = c.expr(x.expr)
+		if t := x.expr.typer(); x.check(x.typ, t) == nil && resolved(t) {
+			x.errorf("type error: cannot convert %s to %s", t, x.typ)
+		}
+		return x
+	case Deref:
+		x.expr = c.expr(x.expr)
+		x.typ = c.deref(x.Line, x.expr.typer())
+		return x
+	case AddrOf:
+		x.id = c.expr(x.id).(Id)
+		return x
+	case Call:
+		args := make([]Node, len(x.args))
+		for i, arg := range x.args {
+			args[i] = c.expr(arg)
+		}
+		x.args = args
+		return x
+	case Rel:
+		x.expr1 = c.expr(x.expr1)
+		x.expr2 = c.expr(x.expr2)
+		t1, t2 := x.expr1.typer(), x.expr2.typer()
+		x.typ = x.check(t1, t2)
+		if x.typ == nil && resolved(t1, t2) {
+			x.errorf("type error: %s %s %s", t1, x.op, t2)
+		} else if c.signedness(x.expr1, x.expr2) || c.signedness(x.expr2, x.expr1) {
+			x.warn(fmt.Sprintf("comparison between signed and unsigned: %s %s %s", t1, x.op, t2))
+		}
+		return x
+	case OrNode:
+		x.Logical = c.logical(x.Logical)
+		return x
+	case AndNode:
+		x.Logical = c.logical(x.Logical)
+		return x
+	case Not:
+		x.expr2 = c.expr(x.expr2)
+		x.expr1 = x.expr2
+		t := x.expr2.typer()
+		x.typ = x.check(t, t)
+		if x.typ == nil && resolved(t) {
+			x.errorf("type error: %s %s", x.op, t)
+		}
+		return x
+	}
+	return n
+}
+
+// signedness reports whether comparing the signed x with the unsigned y
+// is suspicious: the signed value is converted to unsigned, so a negative
+// one compares as a large number. Non-negative constants are fine.
+func (c Checker) signedness(x, y Node) bool {
+	if !IsIntegerType(x.typer()) || IsUnsignedType(x.typer()) || !IsUnsignedType(y.typer()) {
+		return false
+	} else if k, ok := x.(Constant); ok {
+		if n, ok := k.op.(Num); ok && n.value >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Checker) logical(l Logical) Logical {
+	l.expr1 = c.expr(l.expr1)
+	l.expr2 = c.expr(l.expr2)
+	t1, t2 := l.expr1.typer(), l.expr2.typer()
+	l.typ = l.check(t1, t2)
+	if l.typ == nil && resolved(t1, t2) {
+		l.errorf("type error: %s %s %s", t1, l.op, t2)
+	}
+	return l
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Dag is the directed acyclic graph of a basic block, Section 8.5 of the
+// Dragon Book. A leaf stands for the value a variable has on entry to the
+// block or for a constant, an interior node for a value computed in the
+// block, and identical computations share one node. Stores, params,
+// calls and jumps are nodes too, kept in their order.
+//
+// The block is regenerated while the graph is built, so the code keeps
+// the order of the original: a node is computed where it first appears,
+// and a later quad that computes it again only copies it, or nothing at
+// all when it assigns a temp that is local to the block. Such a temp is
+// an alias of the node and uses of it read the name that holds the node.
+type Dag struct {
+	nodes []*DagNode
+	cur   map[Operand]*DagNode // the node whose value a name has now
+	table map[dagKey]*DagNode
+	code  []Quad
+
+	local   map[Operand]bool // temps used in one block only
+	memory  []Operand        // variables whose address is taken
+	version map[*Symbol]int  // stores into each array
+	stores  int              // stores into arrays and through pointers
+}
+
+// DagNode is a node of a Dag. names are the names that have been
+// assigned its value in the regenerated code, aliases the local temps
+// that stand for it without having been assigned.
+type DagNode struct {
+	id      int
+	q       Quad // operator of an interior node; leaf of a leaf
+	kids    []*DagNode
+	leaf    bool
+	names   []Operand
+	aliases []Operand
+}
+
+// dagKey identifies the value of an interior node. A load also depends on
+// the stores before it.
+type dagKey struct {
+	op         Opcode
+	tok        Token
+	kid1, kid2 int
+	extra      Operand
+	version    int
+	stores     int
+}
+
+// NewDag builds the graph of code and regenerates it. local holds the
+// temps used in no other block and memory the variables that a store
+// through a pointer may change.
+func NewDag(code []Quad, local map[Operand]bool, memory []Operand) *Dag {
+	d := &Dag{
+		cur:     map[Operand]*DagNode{},
+		table:   map[dagKey]*DagNode{},
+		local:   local,
+		memory:  memory,
+		version: map[*Symbol]int{},
+	}
+	for _, q := range code {
+		d.quad(q)
+	}
+	d.removeDeadTemps()
+	return d
+}
+
+func (d *Dag) add(n *DagNode) *DagNode {
+	n.id = len(d.nodes) + 1
+	d.nodes = append(d.nodes, n)
+	return n
+}
+
+// node is the node of the value that o has now.
+func (d *Dag) node(o Operand) *DagNode {
+	if n, ok := d.cur[o]; ok {
+		return n
+	}
+	n := d.add(&DagNode{q: Quad{result: o}, leaf: true, names: []Operand{o}})
+	d.cur[o] = n
+	return n
+}
+
+// holder is a name whose value is the node's, or nil.
+func (d *Dag) holder(n *DagNode) (Operand, bool) {
+	for _, h := range n.names {
+		if h.kind == ConstOperand || d.cur[h] == n {
+			return h, true
+		}
+	}
+	return Operand{}, false
+}
+
+// read is the name to read the value of o from.
+func (d *Dag) read(o Operand) Operand {
+	if !o.isName() {
+		return o
+	}
+	if h, ok := d.holder(d.node(o)); ok {
+		return h
+	}
+	return o
+}
+
+// assign makes x hold the value of n. Before x loses its old value, an
+// alias of that value is copied out of x if x is its last holder.
+func (d *Dag) assign(x Operand, n *DagNode) {
+	d.kill(x)
+	d.cur[x] = n
+}
+
+func (d *Dag) kill(x Operand) {
+	old, ok := d.cur[x]
+	if !ok {
+		return
+	}
+	delete(d.cur, x)
+	if _, held := d.holder(old); held {
+		return
+	}
+	for _, a := range old.aliases {
+		if d.cur[a] == old {
+			d.code = append(d.code, Quad{op: OpCopy, arg1: x, result: a})
+			old.names = append(old.names, a)
+			return
+		}
+	}
+}
+
+func (d *Dag) quad(q Quad) {
+	x, assigns := q.defines()
+	if !assigns || q.op == OpCall {
+		d.effect(q)
+		return
+	}
+	var n *DagNode
+	if q.op == OpCopy && q.arg1.typ == x.typ {
+		n = d.node(q.arg1)
+	} else {
+		key, kids := d.key(q)
+		n = d.table[key]
+		if n == nil {
+			n = d.add(&DagNode{q: q, kids: kids})
+			d.table[key] = n
+		}
+	}
+	if d.cur[x] == n {
+		return
+	}
+	h, held := d.holder(n)
+	switch {
+	case held && d.local[x]:
+		d.assign(x, n)
+		n.aliases = append(n.aliases, x)
+		return
+	case held:
+		q = Quad{op: OpCopy, arg1: h, result: x}
+	default:
+		q = d.rewrite(q)
+	}
+	d.assign(x, n)
+	d.code = append(d.code, q)
+	n.names = append(n.names, x)
+}
+
+// key is the table key of the value q computes, and its kids.
+func (d *Dag) key(q Quad) (dagKey, []*DagNode) {
+	k := dagKey{op: q.op, tok: q.tok}
+	var kids []*DagNode
+	switch q.op {
+	case OpBinary:
+		kids = []*DagNode{d.node(q.arg1), d.node(q.arg2)}
+	case OpUnary, OpDeref:
+		kids = []*DagNode{d.node(q.arg1)}
+	case OpCopy:
+		// A copy between types converts implicitly.
+		kids = []*DagNode{d.node(q.arg1)}
+		k.extra = TypeOf(q.result.typ)
+	case OpCast:
+		kids = []*DagNode{d.node(q.arg1)}
+		k.extra = q.arg2
+	case OpLoad:
+		kids = []*DagNode{d.node(q.arg2)}
+		k.extra = q.arg1
+		k.version = d.version[q.arg1.sym]
+	case OpAddr:
+		k.extra = q.arg1
+	}
+	if q.op == OpDeref || q.op == OpLoad {
+		k.stores = d.stores
+	}
+	if len(kids) > 0 {
+		k.kid1 = kids[0].id
+	}
+	if len(kids) > 1 {
+		k.kid2 = kids[1].id
+	}
+	return k, kids
+}
+
+// rewrite makes q read its operands from the names that hold them.
+func (d *Dag) rewrite(q Quad) Quad {
+	if q.op != OpLoad && q.op != OpAddr {
+		q.arg1 = d.read(q.arg1)
+	}
+	if q.op != OpCast {
+		q.arg2 = d.read(q.arg2)
+	}
+	return q
+}
+
+// effect emits a quad that is kept as it is: a store, param, call or
+// jump. A store into an array kills the loads from it, and a store
+// through a pointer kills every load and the variables in memory.
+func (d *Dag) effect(q Quad) {
+	operands := []Operand{q.arg1, q.arg2}
+	if q.op == OpSetDeref {
+		operands = []Operand{q.result, q.arg1}
+	}
+	var kids []*DagNode
+	for _, o := range operands {
+		if o.isName() || o.kind == ConstOperand && q.op != OpCall {
+			kids = append(kids, d.node(o))
+		}
+	}
+	n := d.add(&DagNode{q: q, kids: kids})
+	q = d.rewrite(q)
+	if q.op == OpSetDeref {
+		q.result = d.read(q.result)
+		for _, v := range d.memory {
+			d.kill(v)
+		}
+		d.stores++
+	}
+	if q.op == OpStore {
+		d.version[q.result.sym]++
+		d.stores++
+	}
+	d.code = append(d.code, q)
+	if x, ok := q.defines(); ok {
+		d.assign(x, n)
+		n.names = append(n.names, x)
+	}
+}
+
+// removeDeadTemps deletes the assignments to local temps that are not
+// read later in the block.
+func (d *Dag) removeDeadTemps() {
+	read := map[Operand]bool{}
+	var code []Quad
+	for i := len(d.code) - 1; i >= 0; i-- {
+		q := d.code[i]
+		if x, ok := q.defines(); ok && q.op != OpCall && d.local[x] && !read[x] {
+			continue
+		}
+		for _, o := range q.uses() {
+			read[o] = true
+		}
+		code = append(code, q)
+	}
+	d.code = d.code[:0]
+	for i := len(code) - 1; i >= 0; i-- {
+		d.code = append(d.code, code[i])
+	}
+}
+
+func (n *DagNode) String() string {
+	if n.leaf {
+		return fmt.Sprintf("leaf %s", n.q.result)
+	}
+	q := n.q
+	kids := make([]string, len(n.kids))
+	for i, k := range n.kids {
+		kids[i] = fmt.Sprintf("n%d", k.id)
+	}
+	var op string
+	switch q.op {
+	case OpBinary, OpUnary:
+		op = q.tok.String()
+	case OpCast:
+		op = fmt.Sprintf("(%s)", q.arg2)
+	case OpCopy:
+		op = fmt.Sprintf("(%s)", q.result.typ)
+	case OpLoad:
+		op = fmt.Sprintf("%s[]", q.arg1)
+	case OpStore:
+		op = fmt.Sprintf("%s[]=", q.result)
+	case OpAddr:
+		op = fmt.Sprintf("&%s", q.arg1)
+	case OpDeref:
+		op = "*"
+	case OpSetDeref:
+		op = "*="
+	case OpParam:
+		op = "param"
+	case OpCall:
+		op = fmt.Sprintf("call %s", q.arg1)
+	case OpGoto:
+		op = fmt.Sprintf("goto %s", q.result)
+	case OpIf, OpIfFalse:
+		op = "if"
+		if q.op == OpIfFalse {
+			op = "iffalse"
+		}
+		if q.tok != nil {
+			op += " " + q.tok.String()
+		}
+		kids = append(kids, "goto", q.result.String())
+	}
+	return strings.TrimSpace(op + " " + strings.Join(kids, " "))
+}
+
+// print writes the nodes in order with the names that hold their values
+// at the end of the block.
+func (d *Dag) print(w io.Writer) {
+	for _, n := range d.nodes {
+		var names []string
+		for x, m := range d.cur {
+			if m == n && x.kind != ConstOperand {
+				names = append(names, x.String())
+			}
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "\tn%d\t%s", n.id, n)
+		if len(names) > 0 {
+			fmt.Fprintf(w, "\t%s", strings.Join(names, " "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// cse replaces the code of every block by the code regenerated from its
+// Dag.
+func (g *CFG) cse() {
+	local, memory := g.dagNames()
+	for _, b := range g.blocks {
+		b.code = NewDag(b.code, local, memory).code
+	}
+}
+
+// dags writes the Dag of every block.
+func (g *CFG) dags(w io.Writer) {
+	local, memory := g.dagNames()
+	for _, b := range g.blocks[1 : len(g.blocks)-1] {
+		fmt.Fprintln(w, g.name(b))
+		NewDag(b.code, local, memory).print(w)
+	}
+}
+
+// dagNames returns the temps that only one block uses and the variables
+// whose address is taken.
+func (g *CFG) dagNames() (map[Operand]bool, []Operand) {
+	block := map[Operand]*Block{}
+	local := map[Operand]bool{}
+	for _, b := range g.blocks {
+		for _, q := range b.code {
+			names := q.uses()
+			if x, ok := q.defines(); ok {
+				names = append(names, x)
+			}
+			for _, o := range names {
+				if o.kind != TempOperand {
+					continue
+				}
+				if c, ok := block[o]; !ok {
+					block[o], local[o] = b, true
+				} else if c != b {
+					local[o] = false
+				}
+			}
+		}
+	}
+	return local, g.addressTaken()
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Bits is a set of small integers kept as a bit vector. The analyses
+// number what they track, definitions, names or expressions, and their
+// values are Bits over those numbers.
+type Bits []uint64
+
+func NewBits(n int) Bits { return make(Bits, (n+63)/64) }
+
+// full is the set of 0 .. n-1.
+func full(n int) Bits {
+	s := NewBits(n)
+	for i := 0; i < n; i++ {
+		s.add(i)
+	}
+	return s
+}
+
+func (s Bits) has(i int) bool { return s[i/64]&(1<<uint(i%64)) != 0 }
+func (s Bits) add(i int)      { s[i/64] |= 1 << uint(i%64) }
+func (s Bits) remove(i int)   { s[i/64] &^= 1 << uint(i%64) }
+
+func (s Bits) copy() Bits { return append(Bits(nil), s...) }
+
+func (s Bits) equal(t Bits) bool {
+	for i := range s {
+		if s[i] != t[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Bits) union(t Bits) Bits {
+	u := s.copy()
+	for i := range u {
+		u[i] |= t[i]
+	}
+	return u
+}
+
+func (s Bits) intersect(t Bits) Bits {
+	u := s.copy()
+	for i := range u {
+		u[i] &= t[i]
+	}
+	return u
+}
+
+func (s Bits) minus(t Bits) Bits {
+	u := s.copy()
+	for i := range u {
+		u[i] &^= t[i]
+	}
+	return u
+}
+
+// elems lists the members of s in increasing order.
+func (s Bits) elems() []int {
+	var list []int
+	for i := 0; i < len(s)*64; i++ {
+		if s.has(i) {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+// Direction is the direction in which an analysis propagates values.
+type Direction int
+
+const (
+	Forward Direction = iota
+	Backward
+)
+
+// Analysis is a data-flow problem over a CFG, Section 9.2 of the Dragon
+// Book. A forward problem computes the value at the end of a block from
+// the value at its start, and its start from the meet of the ends of its
+// predecessors; a backward problem does the reverse over successors.
+type Analysis struct {
+	dir Direction
+	// meet combines the values flowing into a block. It is union or
+	// intersection.
+	meet func(x, y Bits) Bits
+	// top is the first guess for every block, the identity of meet.
+	top Bits
+	// boundary is the value at the entry of a forward problem or at the
+	// exit of a backward one.
+	boundary Bits
+	// transfer maps the value on one side of b to the other side.
+	transfer func(b *Block, x Bits) Bits
+}
+
+// Flow is the solution of an Analysis: the values at the start and at
+// the end of every block.
+type Flow struct {
+	in, out map[*Block]Bits
+}
+
+// genKill is the transfer function f(x) = gen ∪ (x - kill) of the
+// bit-vector problems.
+func genKill(gen, kill map[*Block]Bits) func(*Block, Bits) Bits {
+	return func(b *Block, x Bits) Bits { return gen[b].union(x.minus(kill[b])) }
+}
+
+// solve solves a with a worklist: a block is visited again whenever the
+// value flowing into it changes, until nothing changes. The values only
+// move down the lattice, so this reaches the greatest fixed point.
+func (g *CFG) solve(a Analysis) Flow {
+	f := Flow{in: map[*Block]Bits{}, out: map[*Block]Bits{}}
+	// before and after are the sides of a block in the direction of the
+	// analysis, flowsIn the neighbors that flow into it.
+	before, after, start := f.in, f.out, g.entry
+	flowsIn := func(b *Block) []*Block { return b.pred }
+	flowsOut := func(b *Block) []*Block { return b.succ }
+	if a.dir == Backward {
+		before, after, start = f.out, f.in, g.exit
+		flowsIn, flowsOut = flowsOut, flowsIn
+	}
+
+	var work []*Block
+	queued := map[*Block]bool{}
+	for _, b := range g.blocks {
+		if b == start {
+			before[b], after[b] = a.boundary, a.boundary
+			continue
+		}
+		after[b] = a.top
+		work = append(work, b)
+		queued[b] = true
+	}
+	if a.dir == Backward {
+		for i, j := 0, len(work)-1; i < j; i, j = i+1, j-1 {
+			work[i], work[j] = work[j], work[i]
+		}
+	}
+	for len(work) > 0 {
+		b := work[0]
+		work = work[1:]
+		queued[b] = false
+		x := a.top
+		for _, p := range flowsIn(b) {
+			x = a.meet(x, after[p])
+		}
+		before[b] = x
+		y := a.transfer(b, x)
+		if y.equal(after[b]) {
+			continue
+		}
+		after[b] = y
+		for _, s := range flowsOut(b) {
+			if !queued[s] && s != start {
+				work = append(work, s)
+				queued[s] = true
+			}
+		}
+	}
+	return f
+}
+
+// Definition is the quad i of block b that assigns x. A store through a
+// pointer is an ambiguous definition of every variable whose address is
+// taken: it may assign them, so it reaches uses but kills nothing. A
+// definition in the entry block, with i = -1, stands for the value a
+// variable has before it is assigned.
+type Definition struct {
+	b         *Block
+	i         int
+	x         Operand
+	ambiguous bool
+}
+
+// reachingDefinitions numbers the definitions of g and computes which of
+// them reach the start and the end of every block. With entry, every
+// variable also has a definition on entry to the program.
+func (g *CFG) reachingDefinitions(entry bool) ([]Definition, Flow) {
+	memory := g.addressTaken()
+	var defs []Definition
+	of := map[Operand][]int{}
+	define := func(d Definition) {
+		of[d.x] = append(of[d.x], len(defs))
+		defs = append(defs, d)
+	}
+	if entry {
+		for _, v := range g.variables() {
+			define(Definition{b: g.entry, i: -1, x: v})
+		}
+	}
+	for _, b := range g.blocks {
+		for i, q := range b.code {
+			if x, ok := q.defines(); ok {
+				define(Definition{b: b, i: i, x: x})
+			} else if q.op == OpSetDeref {
+				for _, v := range memory {
+					define(Definition{b: b, i: i, x: v, ambiguous: true})
+				}
+			}
+		}
+	}
+
+	gen, kill := map[*Block]Bits{}, map[*Block]Bits{}
+	for _, b := range g.blocks {
+		gen[b], kill[b] = NewBits(len(defs)), NewBits(len(defs))
+	}
+	for d, def := range defs {
+		b := def.b
+		if def.ambiguous {
+			gen[b].add(d)
+			continue
+		}
+		for _, e := range of[def.x] {
+			if e != d {
+				gen[b].remove(e)
+				kill[b].add(e)
+			}
+		}
+		gen[b].add(d)
+	}
+	return defs, g.solve(Analysis{
+		dir:      Forward,
+		meet:     Bits.union,
+		top:      NewBits(len(defs)),
+		boundary: gen[g.entry],
+		transfer: genKill(gen, kill),
+	})
+}
+
+// liveVariables numbers the names of g and computes which of them are
+// live at the start and the end of every block, that is may be read
+// before they are assigned again. A load through a pointer may read any
+// variable whose address is taken.
+func (g *CFG) liveVariables() ([]Operand, Flow) {
+	memory := g.addressTaken()
+	var names []Operand
+	number := map[Operand]int{}
+	for _, b := range g.blocks {
+		for _, q := range b.code {
+			list := q.uses()
+			if x, ok := q.defines(); ok {
+				list = append(list, x)
+			}
+			for _, o := range list {
+				if _, ok := number[o]; !ok {
+					number[o] = len(names)
+					names = append(names, o)
+				}
+			}
+		}
+	}
+
+	use, def := map[*Block]Bits{}, map[*Block]Bits{}
+	for _, b := range g.blocks {
+		use[b], def[b] = NewBits(len(names)), NewBits(len(names))
+		for _, q := range b.code {
+			reads := q.uses()
+			if q.op == OpDeref {
+				reads = append(reads, memory...)
+			}
+			for _, o := range reads {
+				if n, ok := number[o]; ok && !def[b].has(n) {
+					use[b].add(n)
+				}
+			}
+			if x, ok := q.defines(); ok && !use[b].has(number[x]) {
+				def[b].add(number[x])
+			}
+		}
+	}
+	return names, g.solve(Analysis{
+		dir:      Backward,
+		meet:     Bits.union,
+		top:      NewBits(len(names)),
+		boundary: NewBits(len(names)),
+		transfer: genKill(use, def),
+	})
+}
+
+// expression is the right side of a quad that computes a value from its
+// operands.
+type expression struct {
+	op         Opcode
+	tok        Token
+	arg1, arg2 Operand
+}
+
+// expressionOf returns the expression q computes, if it computes one.
+func expressionOf(q Quad) (expression, bool) {
+	switch q.op {
+	case OpBinary, OpUnary, OpCast, OpLoad, OpDeref:
+		return expression{q.op, q.tok, q.arg1, q.arg2}, true
+	}
+	return expression{}, false
+}
+
+func (e expression) String() string {
+	// Without a result a quad prints as " = " and the expression.
+	return strings.TrimPrefix(Quad{op: e.op, tok: e.tok, arg1: e.arg1, arg2: e.arg2}.String(), " = ")
+}
+
+// availableExpressions numbers the expressions of g and computes which of
+// them are available at the start and the end of every block: computed
+// on every path there and not killed since. Assigning a name kills the
+// expressions that read it, a store into an array the loads from it and
+// a store through a pointer every load and the expressions that read a
+// variable whose address is taken.
+func (g *CFG) availableExpressions() ([]expression, Flow) {
+	memory := map[Operand]bool{}
+	for _, v := range g.addressTaken() {
+		memory[v] = true
+	}
+	var exprs []expression
+	number := map[expression]int{}
+	for _, b := range g.blocks {
+		for _, q := range b.code {
+			if e, ok := expressionOf(q); ok {
+				if _, seen := number[e]; !seen {
+					number[e] = len(exprs)
+					exprs = append(exprs, e)
+				}
+			}
+		}
+	}
+	// killedBy lists the expressions that q kills.
+	killedBy := func(q Quad) []int {
+		x, assigns := q.defines()
+		var list []int
+		for n, e := range exprs {
+			switch {
+			case assigns && (e.arg1 == x || e.arg2 == x),
+				q.op == OpStore && e.op == OpLoad && e.arg1 == q.result,
+				q.op == OpSetDeref && (e.op == OpLoad || e.op == OpDeref || memory[e.arg1] || memory[e.arg2]):
+				list = append(list, n)
+			}
+		}
+		return list
+	}
+
+	gen, kill := map[*Block]Bits{}, map[*Block]Bits{}
+	for _, b := range g.blocks {
+		gen[b], kill[b] = NewBits(len(exprs)), NewBits(len(exprs))
+		for _, q := range b.code {
+			if e, ok := expressionOf(q); ok {
+				gen[b].add(number[e])
+				kill[b].remove(number[e])
+			}
+			for _, n := range killedBy(q) {
+				gen[b].remove(n)
+				kill[b].add(n)
+			}
+		}
+	}
+	return exprs, g.solve(Analysis{
+		dir:      Forward,
+		meet:     Bits.intersect,
+		top:      full(len(exprs)),
+		boundary: NewBits(len(exprs)),
+		transfer: genKill(gen, kill),
+	})
+}
+
+// analyze writes the solution of the analysis named by -analyze next to
+// the code of every block:
+//
+//	B2 L3:	pred B1 B6	succ B3 B7
+//		in	{i}
+//		iffalse i < 10 goto L4
+//		out	{i}
+//
+// Reaching definitions are numbered d1, d2, ... and the quads that make
+// them are marked with their numbers.
+func (g *CFG) analyze(w io.Writer, name string) {
+	var f Flow
+	var member func(i int) string
+	mark := func(b *Block, i int) string { return "" }
+	switch name {
+	case "reaching":
+		var defs []Definition
+		defs, f = g.reachingDefinitions(false)
+		member = func(i int) string { return fmt.Sprintf("d%d", i+1) }
+		mark = func(b *Block, i int) string {
+			var list []string
+			for d, def := range defs {
+				if def.b == b && def.i == i {
+					list = append(list, member(d))
+				}
+			}
+			return strings.Join(list, " ")
+		}
+	case "liveness":
+		var names []Operand
+		names, f = g.liveVariables()
+		member = func(i int) string { return names[i].String() }
+	case "available":
+		var exprs []expression
+		exprs, f = g.availableExpressions()
+		member = func(i int) string { return exprs[i].String() }
+	}
+	set := func(s Bits) string {
+		var list []string
+		for _, i := range s.elems() {
+			list = append(list, member(i))
+		}
+		return "{" + strings.Join(list, ", ") + "}"
+	}
+	for _, b := range g.blocks {
+		g.header(w, b)
+		fmt.Fprintf(w, "\tin\t%s\n", set(f.in[b]))
+		for i, q := range b.code {
+			fmt.Fprintf(w, "%s\t%s\n", mark(b, i), q)
+		}
+		fmt.Fprintf(w, "\tout\t%s\n", set(f.out[b]))
+	}
+}
+
+// variables lists the variables that g reads or assigns.
+func (g *CFG) variables() []Operand {
+	var list []Operand
+	seen := map[Operand]bool{}
+	for _, b := range g.blocks {
+		for _, q := range b.code {
+			names := q.uses()
+			if x, ok := q.defines(); ok {
+				names = append(names, x)
+			}
+			for _, o := range names {
+				if o.kind == VarOperand && !seen[o] {
+					seen[o] = true
+					list = append(list, o)
+				}
+			}
+		}
+	}
+	return list
+}
+
+// addressTaken lists the variables whose address is taken, which a
+// store through a pointer may change.
+func (g *CFG) addressTaken() []Operand {
+	var list []Operand
+	seen := map[Operand]bool{}
+	for _, b := range g.blocks {
+		for _, q := range b.code {
+			if q.op == OpAddr && !seen[q.arg1] {
+				seen[q.arg1] = true
+				list = append(list, q.arg1)
+			}
+		}
+	}
+	return list
+}
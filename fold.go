@@ -0,0 +1,467 @@
+package main
+
+// Folder evaluates constant expressions at compile time and simplifies
+// algebraic identities. It runs over the checked tree, so every node has
+// its type. Integer constants wrap to the width of their type, float
+// arithmetic is done in single precision, as in the Java front end,
+// whatever size the target gives a float, and double arithmetic in double
+// precision.
+//
+//	x = 2 * 3 + 1	becomes	x = 7
+//	y = x * 8	becomes	y = x << 3
+//	if (true) s	becomes	s
+//
+// A division by the constant zero is reported with Line.errorf.
+type Folder struct{}
+
+func (f Folder) stmt(n Node) Node {
+	switch s := n.(type) {
+	case Seq:
+		s.stmt1, s.stmt2 = f.stmt(s.stmt1), f.stmt(s.stmt2)
+		if s.stmt1 == nil {
+			return s.stmt2
+		} else if s.stmt2 == nil {
+			return s.stmt1
+		}
+		return s
+	case If:
+		s.expr = f.expr(s.expr)
+		if b, ok := truth(s.expr); ok {
+			if b {
+				return f.stmt(s.stmt)
+			}
+			return nil
+		}
+		s.stmt = f.stmt(s.stmt)
+		return s
+	case Else:
+		s.expr = f.expr(s.expr)
+		if b, ok := truth(s.expr); ok {
+			if b {
+				return f.stmt(s.stmt1)
+			}
+			return f.stmt(s.stmt2)
+		}
+		s.stmt1, s.stmt2 = f.stmt(s.stmt1), f.stmt(s.stmt2)
+		return s
+	case *While:
+		s.expr = f.expr(s.expr)
+		if b, ok := truth(s.expr); ok && !b {
+			return nil
+		}
+		s.stmt = f.stmt(s.stmt)
+		return s
+	case *Do:
+		s.stmt = f.stmt(s.stmt)
+		s.expr = f.expr(s.expr)
+		return s
+	case Set:
+		s.expr = f.expr(s.expr)
+		return s
+	case SetElem:
+		s.index = f.expr(s.index)
+		s.expr = f.expr(s.expr)
+		return s
+	case SetDeref:
+		s.addr = f.expr(s.addr)
+		s.expr = f.expr(s.expr)
+		return s
+	case ArrayCopy:
+		s.dst = f.expr(s.dst)
+		s.src = f.expr(s.src)
+		return s
+	case Print:
+		for i, x := range s.args {
+			s.args[i] = f.expr(x)
+		}
+		return s
+	}
+	return n
+}
+
+func (f Folder) expr(n Node) Node {
+	switch x := n.(type) {
+	case Access:
+		x.index = f.expr(x.index)
+		return x
+	case Bound:
+		x.index = f.expr(x.index)
+		if c, ok := numeric(x.index); ok && IsIntegerType(c.typ) && inRange(x.Line, x.array, c, x.dim) {
+			return c
+		}
+		return x
+	case Arith:
+		x.expr1 = f.expr(x.expr1)
+		x.expr2 = f.expr(x.expr2)
+		return f.arith(x)
+	case Unary:
+		x.expr = f.expr(x.expr)
+		if c, ok := numeric(x.expr); ok && x.op.Tag() == MINUS {
+			i, r := value(c)
+			return constant(x.typ, -i, -r)
+		}
+		return x
+	case Cast:
+		x.expr = f.expr(x.expr)
+		if c, ok := x.expr.(Constant); ok && (IsNumbericType(c.typ) || IsEnumType(c.typ)) && IsNumbericType(x.typ) {
+			i, r := value(promote(c, x.typ))
+			return constant(x.typ, i, r)
+		}
+		return x
+	case Deref:
+		x.expr = f.expr(x.expr)
+		return x
+	case Call:
+		for i, arg := range x.args {
+			x.args[i] = f.expr(arg)
+		}
+		return x
+	case Rel:
+		x.expr1 = f.expr(x.expr1)
+		x.expr2 = f.expr(x.expr2)
+		return f.rel(x)
+	case OrNode:
+		x.expr1 = f.expr(x.expr1)
+		x.expr2 = f.expr(x.expr2)
+		if b, ok := truth(x.expr1); ok {
+			if b {
+				return ConstantTrue
+			}
+			return x.expr2
+		} else if b, ok := truth(x.expr2); ok && pure(x.expr1) {
+			if b {
+				return ConstantTrue
+			}
+			return x.expr1
+		}
+		return x
+	case AndNode:
+		x.expr1 = f.expr(x.expr1)
+		x.expr2 = f.expr(x.expr2)
+		if b, ok := truth(x.expr1); ok {
+			if !b {
+				return ConstantFalse
+			}
+			return x.expr2
+		} else if b, ok := truth(x.expr2); ok && pure(x.expr1) {
+			if !b {
+				return ConstantFalse
+			}
+			return x.expr1
+		}
+		return x
+	case Not:
+		x.expr2 = f.expr(x.expr2)
+		x.expr1 = x.expr2
+		if b, ok := truth(x.expr2); ok {
+			return boolean(!b)
+		}
+		return x
+	}
+	return n
+}
+
+func (f Folder) arith(x Arith) Node {
+	c1, ok1 := numeric(x.expr1)
+	c2, ok2 := numeric(x.expr2)
+	op := x.op.Tag()
+	if ok2 && op == '/' && isValue(c2, 0) {
+		x.errorf("division by zero")
+		return x
+	}
+	if ok1 && ok2 && IsNumbericType(x.typ) {
+		return binary(op, x.typ, c1, c2)
+	}
+
+	// Identities. An operand is only dropped when nothing is lost: it
+	// has no effect and the type of the expression does not change.
+	keeps := func(y Node) bool { return y.typer() == x.typ }
+	switch {
+	case op == '+' && ok2 && isValue(c2, 0) && keeps(x.expr1),
+		op == '-' && ok2 && isValue(c2, 0) && keeps(x.expr1),
+		op == '*' && ok2 && isValue(c2, 1) && keeps(x.expr1),
+		op == '/' && ok2 && isValue(c2, 1) && keeps(x.expr1):
+		return x.expr1
+	case op == '+' && ok1 && isValue(c1, 0) && keeps(x.expr2),
+		op == '*' && ok1 && isValue(c1, 1) && keeps(x.expr2):
+		return x.expr2
+	case op == '*' && (ok1 && isValue(c1, 0) && pure(x.expr2) || ok2 && isValue(c2, 0) && pure(x.expr1)):
+		return constant(x.typ, 0, 0)
+	case op == '*' && IsIntegerType(x.typ):
+		if k, ok := log2(c2, ok2); ok {
+			return shift(x.expr1, k, x.typ)
+		} else if k, ok := log2(c1, ok1); ok {
+			return shift(x.expr2, k, x.typ)
+		}
+	}
+	return x
+}
+
+func shift(x Node, k int, t Typer) Arith {
+	a := NewArith(Shl, x, NewConstantInt(k))
+	a.Line = Line(x.Pos())
+	a.typ = t
+	return a
+}
+
+func (f Folder) rel(x Rel) Node {
+	c1, ok1 := x.expr1.(Constant)
+	c2, ok2 := x.expr2.(Constant)
+	if !ok1 || !ok2 {
+		return x
+	}
+	var cmp int
+	t1, t2 := c1.typ, c2.typ
+	switch {
+	case IsNumbericType(t1) && IsNumbericType(t2):
+		p := MaxType(t1, t2)
+		i1, r1 := value(c1)
+		i2, r2 := value(c2)
+		if IsIntegerType(p) {
+			i1, i2 = wrap(p, i1), wrap(p, i2)
+			if IsUnsignedType(p) {
+				cmp = compare(uint64(i1) < uint64(i2), i1 == i2)
+			} else {
+				cmp = compare(i1 < i2, i1 == i2)
+			}
+		} else {
+			cmp = compare(r1 < r2, r1 == r2)
+		}
+	case IsEnumType(t1):
+		i1, _ := value(c1)
+		i2, _ := value(c2)
+		cmp = compare(i1 < i2, i1 == i2)
+	case t1 == Bool:
+		b1, _ := truth(c1)
+		b2, _ := truth(c2)
+		switch x.op.Tag() {
+		case EQ:
+			return boolean(b1 == b2)
+		case NE:
+			return boolean(b1 != b2)
+		}
+		return x
+	default:
+		return x
+	}
+	switch x.op.Tag() {
+	case '<':
+		return boolean(cmp < 0)
+	case LE:
+		return boolean(cmp <= 0)
+	case '>':
+		return boolean(cmp > 0)
+	case GE:
+		return boolean(cmp >= 0)
+	case EQ:
+		return boolean(cmp == 0)
+	case NE:
+		return boolean(cmp != 0)
+	}
+	return x
+}
+
+func compare(less, equal bool) int {
+	if equal {
+		return 0
+	} else if less {
+		return -1
+	}
+	return 1
+}
+
+// binary computes c1 op c2 in type t.
+func binary(op Tag, t Typer, c1, c2 Constant) Constant {
+	c1, c2 = promote(c1, t), promote(c2, t)
+	i1, r1 := value(c1)
+	i2, r2 := value(c2)
+	switch {
+	case IsUnsignedType(t):
+		u1, u2 := uint64(wrap(t, i1)), uint64(wrap(t, i2))
+		switch op {
+		case '+':
+			return constant(t, int64(u1+u2), 0)
+		case '-':
+			return constant(t, int64(u1-u2), 0)
+		case '*':
+			return constant(t, int64(u1*u2), 0)
+		}
+		return constant(t, int64(u1/u2), 0)
+	case IsIntegerType(t):
+		switch op {
+		case '+':
+			return constant(t, i1+i2, 0)
+		case '-':
+			return constant(t, i1-i2, 0)
+		case '*':
+			return constant(t, i1*i2, 0)
+		}
+		return constant(t, i1/i2, 0)
+	case t == Float:
+		f1, f2 := float32(r1), float32(r2)
+		switch op {
+		case '+':
+			return constant(t, 0, float64(f1+f2))
+		case '-':
+			return constant(t, 0, float64(f1-f2))
+		case '*':
+			return constant(t, 0, float64(f1*f2))
+		}
+		return constant(t, 0, float64(f1/f2))
+	}
+	switch op {
+	case '+':
+		return constant(t, 0, r1+r2)
+	case '-':
+		return constant(t, 0, r1-r2)
+	case '*':
+		return constant(t, 0, r1*r2)
+	}
+	return constant(t, 0, r1/r2)
+}
+
+// value is the value of the numeric constant c as an integer and as a
+// real number.
+func value(c Constant) (int64, float64) {
+	switch v := c.op.(type) {
+	case Num:
+		return int64(v.value), float64(v.value)
+	case Real:
+		return int64(v.value), v.value
+	}
+	return 0, 0
+}
+
+// constant makes the constant of type t with the value i, for integer
+// types, or r.
+func constant(t Typer, i int64, r float64) Constant {
+	if IsIntegerType(t) {
+		return NewConstant(Num{value: int(wrap(t, i))}, t)
+	} else if t == Float {
+		r = float64(float32(r))
+	} else if t == Double {
+		return NewConstant(NewDouble(r), t)
+	}
+	return NewConstant(NewReal(r), t)
+}
+
+// wrap truncates v to the width of the integer type t and sign extends
+// it when t is signed.
+func wrap(t Typer, v int64) int64 {
+	bits := uint(t.Width() * 8)
+	if bits >= 64 {
+		return v
+	}
+	v &= 1<<bits - 1
+	if !IsUnsignedType(t) && v >= 1<<(bits-1) {
+		v -= 1 << bits
+	}
+	return v
+}
+
+// constantIndex computes the value of an integer index made of
+// constants, such as -1, 3+7 or (int) 2.5, without reporting errors: the parser
+// checks it against the array before anything is folded.
+func constantIndex(n Node) (Constant, bool) {
+	switch x := n.(type) {
+	case Constant:
+		return x, IsIntegerType(x.typ)
+	case Unary:
+		if c, ok := constantIndex(x.expr); ok && x.op.Tag() == MINUS && IsIntegerType(x.typ) {
+			i, _ := value(c)
+			return constant(x.typ, -i, 0), true
+		}
+	case Arith:
+		c1, ok1 := constantIndex(x.expr1)
+		c2, ok2 := constantIndex(x.expr2)
+		switch op := x.op.Tag(); {
+		case !ok1 || !ok2 || !IsIntegerType(x.typ):
+		case op == '+', op == '-', op == '*', op == '/' && !isValue(c2, 0):
+			return binary(op, x.typ, c1, c2), true
+		}
+	case Cast:
+		c, ok := constantIndex(x.expr)
+		if !ok {
+			c, ok = numeric(x.expr)
+		}
+		if ok && IsIntegerType(x.typ) {
+			i, r := value(c)
+			return constant(x.typ, i, r), true
+		}
+	}
+	return Constant{}, false
+}
+
+func numeric(n Node) (Constant, bool) {
+	c, ok := n.(Constant)
+	return c, ok && IsNumbericType(c.typ)
+}
+
+func isValue(c Constant, v int64) bool {
+	i, r := value(c)
+	if _, ok := c.op.(Real); ok {
+		return r == float64(v)
+	}
+	return i == v
+}
+
+// log2 returns k when c is the integer 2**k, k > 0.
+func log2(c Constant, ok bool) (int, bool) {
+	i, _ := value(c)
+	if !ok || !IsIntegerType(c.typ) || i < 2 || i&(i-1) != 0 {
+		return 0, false
+	}
+	k := 0
+	for ; i > 1; i >>= 1 {
+		k++
+	}
+	return k, true
+}
+
+// truth reports the value of x if it is a boolean constant.
+func truth(x Node) (value, ok bool) {
+	if c, isConst := x.(Constant); isConst {
+		switch c.op.Tag() {
+		case TRUE:
+			return true, true
+		case FALSE:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func boolean(b bool) Constant {
+	if b {
+		return ConstantTrue
+	}
+	return ConstantFalse
+}
+
+// pure reports whether evaluating x has no effect, so that it can be
+// dropped. Calls and bounds checks have one.
+func pure(n Node) bool {
+	switch x := n.(type) {
+	case Id, Constant, Temp:
+		return true
+	case Arith:
+		return pure(x.expr1) && pure(x.expr2)
+	case Unary:
+		return pure(x.expr)
+	case Cast:
+		return pure(x.expr)
+	case Deref:
+		return pure(x.expr)
+	case Access:
+		return pure(x.index)
+	case Rel:
+		return pure(x.expr1) && pure(x.expr2)
+	case OrNode:
+		return pure(x.expr1) && pure(x.expr2)
+	case AndNode:
+		return pure(x.expr1) && pure(x.expr2)
+	case Not:
+		return pure(x.expr2)
+	}
+	return false
+}
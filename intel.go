@@ -2,18 +2,19 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"runtime/debug"
 )
 
 type Node interface {
 	Pos() int
-	reduce() Node
+	reduce(out Emitter) Node
 	String() string
 
-	gen(b, a int)
-	genNode() Node
+	gen(out Emitter, b, a int)
+	genNode(out Emitter) Node
 
-	jumping(t, f int) // ?
+	jumping(out Emitter, t, f int) // ?
 
 	typer() Typer
 }
@@ -28,35 +29,52 @@ func newLabel() int {
 	return labelCount
 }
 
-func emitLabel(i int) {
-	if option.el {
-		debug.PrintStack()
-	}
-	fmt.Printf("L%d:", i)
-}
+type Line int
 
-func emit(str string, args ...interface{}) {
-	fmt.Println("\t" + fmt.Sprintf(str, args...))
+func (l Line) Pos() int { return int(l) }
+func (l Line) error(msg string) {
+	panic(fmt.Errorf("line %d: %s", l, msg))
+}
+func (l Line) warn(msg string) {
+	fmt.Fprintf(os.Stderr, "line %d: warning: %s\n", l, msg)
 }
 
-func emitjumps(test string, t, f int) {
-	if t != 0 && f != 0 {
-		emit("if %s goto L%d", test, t)
-		emit("goto L%d", f)
-	} else if t != 0 {
-		emit("if %s goto L%d", test, t)
-	} else if f != 0 {
-		// log.Printf("--> %T\n", test)
-		// debug.PrintStack()
-		emit("iffalse %s goto L%d", test, f)
-	}
+// errorCount counts the diagnostics reported with errorf. Unlike error,
+// errorf lets the compiler go on and report further problems.
+var errorCount int
+
+func (l Line) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "line %d: %s\n", l, fmt.Sprintf(format, args...))
+	errorCount++
 }
 
-type Line int
+// converts reports whether a value of type t needs a conversion
+// instruction to become a w. Conversions are only visible with -widen,
+// which is off by default only so that the output of the programs in
+// java/tests stays what the Java front end prints; without it x = i + f
+// leaves the conversion of i implicit.
+func converts(t, w Typer) bool {
+	return option.widen && t != w && IsNumbericType(t) && IsNumbericType(w)
+}
 
-func (l Line) Pos() int { return int(l) }
-func (l Line) error(msg string) {
-	panic(fmt.Errorf("line %d: %s", l, msg))
+// widen is the widen function of the Dragon Book: it returns x when t and
+// w agree, and otherwise emits
+//
+//	t1 = (float) i
+//
+// and returns the temporary.
+func widen(out Emitter, x Node, t, w Typer) Node {
+	if c, ok := x.(Constant); ok && IsNumbericType(c.typ) {
+		if c = promote(c, w); c.typ == w {
+			return c
+		}
+	}
+	if !converts(t, w) {
+		return x
+	}
+	tmp := NewTemp(w)
+	out.Convert(tmp, x)
+	return tmp
 }
 
 type Stmt struct {
@@ -70,13 +88,13 @@ var StmtEnclosing Node
 
 // var StmtNull = &Stmt{}
 
-func (s Stmt) gen(b, a int)     {}
-func (s Stmt) String() string   { return "" }
-func (s Stmt) reduce() Node     { return s }
-func (s Stmt) genNode() Node    { return s }
-func (s Stmt) jumping(t, f int) {}
-func (s Stmt) typer() Typer     { return s.typ }
-func (s Stmt) After() int       { return s.after }
+func (s Stmt) gen(out Emitter, b, a int)     {}
+func (s Stmt) String() string                { return "" }
+func (s Stmt) reduce(out Emitter) Node       { return s }
+func (s Stmt) genNode(out Emitter) Node      { return s }
+func (s Stmt) jumping(out Emitter, t, f int) {}
+func (s Stmt) typer() Typer                  { return s.typ }
+func (s Stmt) After() int                    { return s.after }
 
 type Seq struct {
 	Stmt
@@ -89,16 +107,18 @@ func NewSeq(s1, s2 Node) Seq {
 	return s
 }
 
-func (s Seq) gen(b, a int) {
-	if s.stmt1 == nil {
-		s.stmt2.gen(b, a)
+func (s Seq) gen(out Emitter, b, a int) {
+	if s.stmt1 == nil && s.stmt2 == nil {
+		return
+	} else if s.stmt1 == nil {
+		s.stmt2.gen(out, b, a)
 	} else if s.stmt2 == nil {
-		s.stmt1.gen(b, a)
+		s.stmt1.gen(out, b, a)
 	} else {
 		label := newLabel()
-		s.stmt1.gen(b, label)
-		emitLabel(label)
-		s.stmt2.gen(label, a)
+		s.stmt1.gen(out, b, label)
+		out.Label(label)
+		s.stmt2.gen(out, label, a)
 	}
 }
 
@@ -114,13 +134,13 @@ func NewExpr(op Token, typ Typer) Expr {
 	return e
 }
 
-func (e Expr) genNode() Node    { return e }
-func (e Expr) gen(int, int)     {}
-func (e Expr) reduce() Node     { return e }
-func (e Expr) jumping(t, f int) { emitjumps(e.op.String(), t, f) }
-func (e Expr) Tag() Tag         { return e.op.Tag() }
-func (e Expr) String() string   { return e.op.String() }
-func (e Expr) typer() Typer     { return e.typ }
+func (e Expr) genNode(out Emitter) Node      { return e }
+func (e Expr) gen(Emitter, int, int)         {}
+func (e Expr) reduce(out Emitter) Node       { return e }
+func (e Expr) jumping(out Emitter, t, f int) { out.CondJump(e, t, f) }
+func (e Expr) Tag() Tag                      { return e.op.Tag() }
+func (e Expr) String() string                { return e.op.String() }
+func (e Expr) typer() Typer                  { return e.typ }
 
 type If struct {
 	Stmt
@@ -128,12 +148,12 @@ type If struct {
 	stmt Node
 }
 
-func (i If) gen(b, a int) {
+func (i If) gen(out Emitter, b, a int) {
 	label := newLabel()
-	i.expr.jumping(0, a)
-	emitLabel(label)
+	i.expr.jumping(out, 0, a)
+	out.Label(label)
 	if i.stmt != nil {
-		i.stmt.gen(label, a)
+		i.stmt.gen(out, label, a)
 	}
 }
 
@@ -143,17 +163,19 @@ type Else struct {
 	stmt1, stmt2 Node
 }
 
-func (e Else) gen(b, a int) {
+func (e Else) gen(out Emitter, b, a int) {
 	label1 := newLabel()
 	label2 := newLabel()
-	e.expr.jumping(0, label2)
-	emitLabel(label1)
+	e.expr.jumping(out, 0, label2)
+	out.Label(label1)
 	if e.stmt1 != nil {
-		e.stmt1.gen(label1, a)
+		e.stmt1.gen(out, label1, a)
+	}
+	out.Goto(a)
+	out.Label(label2)
+	if e.stmt2 != nil {
+		e.stmt2.gen(out, label2, a)
 	}
-	emit("goto L%d", a)
-	emitLabel(label2)
-	e.stmt2.gen(label2, a)
 }
 
 type While struct {
@@ -162,24 +184,23 @@ type While struct {
 	stmt Node
 }
 
-func (w While) genNode() Node  { return &w }
-func (w While) reduce() Node   { return &w }
-func (w While) String() string { return "while" }
-func (w *While) gen(b, a int) {
+func (w While) genNode(out Emitter) Node { return &w }
+func (w While) reduce(out Emitter) Node  { return &w }
+func (w While) String() string           { return "while" }
+func (w *While) gen(out Emitter, b, a int) {
 	w.after = a
-	w.expr.jumping(0, a)
+	w.expr.jumping(out, 0, a)
 	label := newLabel()
-	emitLabel(label)
-	w.stmt.gen(label, b)
-	emit("goto L%d", b)
+	out.Label(label)
+	if w.stmt != nil {
+		w.stmt.gen(out, label, b)
+	}
+	out.Goto(b)
 }
 
 func (d *While) init(expr Node, stmt Node) {
 	d.expr = expr
 	d.stmt = stmt
-	if expr.typer().Lexeme() != Bool.Lexeme() {
-		d.error("boolean required in while")
-	}
 }
 
 type Do struct {
@@ -188,20 +209,22 @@ type Do struct {
 	stmt Node
 }
 
-func (d Do) gen(b, a int) {
+func (d Do) genNode(out Emitter) Node { return &d }
+func (d Do) reduce(out Emitter) Node  { return &d }
+func (d Do) String() string           { return "do" }
+func (d *Do) gen(out Emitter, b, a int) {
 	d.after = a
 	label := newLabel()
-	d.stmt.gen(b, label)
-	emitLabel(label)
-	d.expr.jumping(b, 0)
+	if d.stmt != nil {
+		d.stmt.gen(out, b, label)
+	}
+	out.Label(label)
+	d.expr.jumping(out, b, 0)
 }
 
 func (d *Do) init(stmt Node, expr Node) {
 	d.stmt = stmt
 	d.expr = expr
-	if expr.typer().Lexeme() != Bool.Lexeme() {
-		d.error("boolean required in do")
-	}
 }
 
 type Break struct {
@@ -219,8 +242,8 @@ func NewBreak() Break {
 	return b
 }
 
-func (br Break) gen(b, a int) {
-	emit("goto L%d", br.stmt.(interface {
+func (br Break) gen(out Emitter, b, a int) {
+	out.Goto(br.stmt.(interface {
 		After() int
 	}).After())
 }
@@ -228,8 +251,36 @@ func (br Break) gen(b, a int) {
 type Id struct {
 	Expr
 	offset int
+	sym    *Symbol
+}
+
+// Symbol is the declaration an identifier resolves to. The parser binds
+// every Id to its Symbol while the scopes are open.
+type Symbol struct {
+	name   Token
+	typ    Typer
+	offset int
+	scope  *Env
+	depth  int // nesting depth of the declaring block, 0 for the program
+	pos    Pos // of the declaration
+	reads  []Pos
+	writes []Pos
 }
 
+func NewId(tok Token, p Typer, offset int) Id {
+	id := Id{Expr: NewExpr(tok, p), offset: offset}
+	id.sym = &Symbol{name: tok, typ: p, offset: offset}
+	return id
+}
+
+func (i Id) reduce(out Emitter) Node       { return i }
+func (i Id) genNode(out Emitter) Node      { return i }
+func (i Id) jumping(out Emitter, t, f int) { out.CondJump(i, t, f) }
+
+// used reports whether the variable is ever read. Assigning a variable
+// does not use it.
+func (s *Symbol) used() bool { return len(s.reads) > 0 }
+
 // func (i Id) Tag() Tag {
 // 	return i.typ
 // }
@@ -249,33 +300,52 @@ func NewSet(id Id, expr Node) Set {
 	// log.Printf("--> %+v\n", id.typer())
 	// log.Printf("--> %T\n", expr)
 	s.typ = s.check(id.typer(), expr.typer())
-	if s.typ == nil {
-		s.error("type error")
-	}
 	return s
 }
 
 func (s *Set) check(p1, p2 Typer) Typer {
+	if !resolved(p1, p2) {
+		return nil
+	}
 	if IsNumbericType(p1) && IsNumbericType(p2) {
 		return p2
 	} else if p1.Lexeme() == Bool.Lexeme() && p2.Lexeme() == Bool.Lexeme() {
 		return p2
+	} else if (IsPointerType(p1) || IsEnumType(p1)) && p1 == p2 {
+		return p2
 	}
 	return nil
 }
 
-func (s Set) gen(b, a int) {
-	emit("%s = %s", s.id, s.expr.genNode())
+func (s Set) gen(out Emitter, b, a int) {
+	if t := s.expr.typer(); converts(t, s.id.typ) {
+		out.Assign(s.id, widen(out, s.expr.reduce(out), t, s.id.typ))
+		return
+	}
+	x := s.expr.genNode(out)
+	if c, ok := x.(Constant); ok && IsNumbericType(c.typ) {
+		x = promote(c, s.id.typ)
+	}
+	out.Assign(s.id, x)
+}
+
+// promote makes a float literal used as a double a double constant with
+// all the digits of the literal, without a conversion at run time.
+func promote(c Constant, w Typer) Constant {
+	if r, ok := c.op.(Real); ok && w == Double && !r.double {
+		return NewConstant(NewDouble(r.digits), Double)
+	}
+	return c
 }
 
 type Op struct{ Expr }
 
-func (o Op) genNode() Node { return o }
-func (o Op) reduce() Node {
+func (o Op) genNode(out Emitter) Node { return o }
+func (o Op) reduce(out Emitter) Node {
 	debug.PrintStack()
-	x := o.genNode()
+	x := o.genNode(out)
 	t := NewTemp(o.typ)
-	emit("%s = %s", t, x)
+	out.Assign(t, x)
 	return t
 }
 
@@ -294,7 +364,10 @@ func NewTemp(p Typer) Temp {
 	return t
 }
 
-func (t Temp) String() string { return fmt.Sprintf("t%d", t.number) }
+func (t Temp) reduce(out Emitter) Node        { return t }
+func (t Temp) genNode(out Emitter) Node       { return t }
+func (t Temp) String() string                 { return fmt.Sprintf("t%d", t.number) }
+func (t Temp) jumping(out Emitter, tl, f int) { out.CondJump(t, tl, f) }
 
 type Access struct {
 	Op
@@ -310,16 +383,77 @@ func NewAccess(a Id, i Node, p Typer) Access {
 	return as
 }
 
-func (a Access) genNode() Node    { return NewAccess(a.array, a.index.reduce(), a.typ) }
-func (a Access) jumping(t, f int) { emitjumps(a.reduce().String(), t, f) }
-func (a Access) String() string   { return fmt.Sprintf("%s [ %s ]", a.array, a.index) }
-func (a Access) reduce() Node {
-	x := a.genNode()
+func (a Access) genNode(out Emitter) Node      { return NewAccess(a.array, a.index.reduce(out), a.typ) }
+func (a Access) jumping(out Emitter, t, f int) { out.CondJump(a.reduce(out), t, f) }
+func (a Access) String() string                { return fmt.Sprintf("%s [ %s ]", a.array, a.index) }
+func (a Access) reduce(out Emitter) Node {
+	x := a.genNode(out)
 	t := NewTemp(a.typ)
-	emit("%s = %s", t, x)
+	out.Assign(t, x)
 	return t
 }
 
+// Bound is an array index checked against the size of its dimension.
+// Reducing it emits
+//
+//	if i < 0 goto L7
+//	if i >= 10 goto L7
+//
+// where L7 is boundsLabel, the label of the shared bounds error handler
+// that the program emits after its last statement.
+type Bound struct {
+	Op
+	index Node
+	array Id
+	dim   Array
+}
+
+var boundsLabel int
+
+func NewBound(a Id, i Node, dim Array) Bound {
+	var b Bound
+	b.Expr = NewExpr(NewWord("bound", INDEX), i.typer())
+	b.index = i
+	b.array = a
+	b.dim = dim
+	return b
+}
+
+// inRange reports whether the constant index c is within the dimension
+// dim of array a, and reports the error at line l when it is not.
+func inRange(l Line, a Id, c Constant, dim Array) bool {
+	if i, _ := value(c); i < 0 || i >= int64(dim.size) {
+		l.errorf("index %d out of range for %s of type %s", i, a, dim)
+		return false
+	}
+	return true
+}
+
+func (b Bound) genNode(out Emitter) Node { return b.reduce(out) }
+func (b Bound) String() string           { return b.index.String() }
+func (b Bound) reduce(out Emitter) Node {
+	if boundsLabel == 0 {
+		boundsLabel = newLabel()
+	}
+	x := b.index.reduce(out)
+	out.CondJump(NewRel(Tag('<'), x, NewConstantInt(0)), boundsLabel, 0)
+	out.CondJump(NewRel(Ge, x, NewConstantInt(b.dim.size)), boundsLabel, 0)
+	return x
+}
+
+// emitBoundsHandler emits the bounds error handler after the program, if
+// any index was checked.
+func emitBoundsHandler(out Emitter) {
+	if boundsLabel == 0 {
+		return
+	}
+	exit := newLabel()
+	out.Goto(exit)
+	out.Label(boundsLabel)
+	out.Call("bounds_error", 0)
+	out.Label(exit)
+}
+
 type SetElem struct {
 	Stmt
 	array Id
@@ -333,13 +467,143 @@ func NewSetElem(x Access, y Node) SetElem {
 	se.index = x.index
 	se.expr = y
 	se.Line = Line(lexerLine)
-	if se.check(x.typer(), y.typer()) == nil {
-		se.error("type error")
-	}
+	se.typ = x.typer()
 	return se
 }
 
 func (s *SetElem) check(p1, p2 Typer) Typer {
+	if !resolved(p1, p2) {
+		return nil
+	}
+	_, ok1 := p1.(Array)
+	_, ok2 := p2.(Array)
+	if ok1 || ok2 {
+		return nil
+	} else if p1 == p2 {
+		return p2
+	} else if IsNumbericType(p1) && IsNumbericType(p2) {
+		return p2
+	}
+	return nil
+}
+
+func (s SetElem) gen(out Emitter, b, a int) {
+	out.IndexedStore(s.array, s.index.reduce(out), widen(out, s.expr.reduce(out), s.expr.typer(), s.typ))
+}
+
+// ArrayCopy assigns a whole array, one element at a time. Array types are
+// equivalent when they are structurally identical: the same sizes and the
+// same element type.
+type ArrayCopy struct {
+	Stmt
+	dst, src Node
+}
+
+func NewArrayCopy(x, y Node) ArrayCopy {
+	var c ArrayCopy
+	c.Line = Line(lexerLine)
+	c.typ = x.typer()
+	c.dst, c.src = x, y
+	return c
+}
+
+func (c *ArrayCopy) check(p1, p2 Typer) Typer {
+	if p1 == p2 {
+		return p1
+	}
+	return nil
+}
+
+// rows splits a copied array into the variable holding it and the byte
+// offset of the row within it, nil for a whole variable.
+func (c ArrayCopy) row(x Node) (Id, Node, bool) {
+	switch x := x.(type) {
+	case Id:
+		return x, nil, true
+	case Access:
+		return x.array, x.index, true
+	}
+	return Id{}, nil, false
+}
+
+func (c ArrayCopy) gen(out Emitter, b, a int) {
+	elem := c.typ
+	for IsArrayType(elem) {
+		elem = elem.(Array).elem
+	}
+	dstArray, dst, _ := c.row(c.dst)
+	srcArray, src, _ := c.row(c.src)
+	if dst != nil {
+		dst = dst.reduce(out)
+	}
+	if src != nil {
+		src = src.reduce(out)
+	}
+	for off := 0; off < c.typ.Width(); off += elem.Width() {
+		i := c.at(out, src, off)
+		t := NewTemp(elem)
+		out.Assign(t, NewAccess(srcArray, i, elem))
+		out.IndexedStore(dstArray, c.at(out, dst, off), t)
+	}
+}
+
+func (c ArrayCopy) at(out Emitter, base Node, off int) Node {
+	if base == nil {
+		return NewConstantInt(off)
+	} else if off == 0 {
+		return base
+	}
+	t := NewTemp(Int)
+	out.Assign(t, NewArith(Tag('+'), base, NewConstantInt(off)))
+	return t
+}
+
+// Print writes each argument with the runtime intrinsic for its type and
+// then ends the line:
+//
+//	param x
+//	call print_int, 1
+//	call print_newline, 0
+type Print struct {
+	Stmt
+	args []Node
+}
+
+func NewPrint(args []Node) Print {
+	var pr Print
+	pr.Line = Line(lexerLine)
+	pr.args = args
+	return pr
+}
+
+func (pr Print) gen(out Emitter, b, a int) {
+	for _, x := range pr.args {
+		out.Param(x.reduce(out))
+		out.Call(intrinsic("print", x.typer()), 1)
+	}
+	out.Call("print_newline", 0)
+}
+
+// SetDeref is a store through a pointer: *x = y.
+type SetDeref struct {
+	Stmt
+	addr Node
+	expr Node
+}
+
+func NewSetDeref(x Deref, y Node) SetDeref {
+	var sd SetDeref
+	sd.addr = x.expr
+	sd.expr = y
+	sd.Line = Line(lexerLine)
+	sd.typ = x.typer()
+	return sd
+}
+
+func (s *SetDeref) check(p1, p2 Typer) Typer {
+	if !resolved(p1, p2) {
+		return nil
+	}
 	_, ok1 := p1.(Array)
 	_, ok2 := p2.(Array)
 	if ok1 || ok2 {
@@ -352,8 +616,97 @@ func (s *SetElem) check(p1, p2 Typer) Typer {
 	return nil
 }
 
-func (s SetElem) gen(b, a int) {
-	emit("%s [ %s ] = %s", s.array, s.index.reduce(), s.expr.reduce())
+func (s SetDeref) gen(out Emitter, b, a int) {
+	out.Store(s.addr.reduce(out), widen(out, s.expr.reduce(out), s.expr.typer(), s.typ))
+}
+
+// AddrOf takes the address of a variable: x = &y.
+type AddrOf struct {
+	Op
+	id Id
+}
+
+func NewAddrOf(id Id) AddrOf {
+	var a AddrOf
+	a.Expr = NewExpr(Tag('&'), NewPointer(id.typ))
+	a.id = id
+	return a
+}
+
+func (a AddrOf) genNode(out Emitter) Node { return a }
+func (a AddrOf) String() string           { return fmt.Sprintf("&%s", a.id) }
+func (a AddrOf) reduce(out Emitter) Node {
+	t := NewTemp(a.typ)
+	out.Assign(t, a)
+	return t
+}
+
+// Deref loads through a pointer: x = *y.
+type Deref struct {
+	Op
+	expr Node
+}
+
+func NewDeref(x Node) Deref {
+	var d Deref
+	d.Expr = NewExpr(Tag('*'), nil)
+	d.expr = x
+	if p, ok := x.typer().(Pointer); ok {
+		d.typ = p.elem
+	}
+	return d
+}
+
+func (d Deref) genNode(out Emitter) Node {
+	x := d
+	x.expr = d.expr.reduce(out)
+	return x
+}
+
+func (d Deref) jumping(out Emitter, t, f int) { out.CondJump(d.reduce(out), t, f) }
+func (d Deref) String() string                { return fmt.Sprintf("*%s", d.expr) }
+func (d Deref) reduce(out Emitter) Node {
+	x := d.genNode(out)
+	t := NewTemp(d.typ)
+	out.Assign(t, x)
+	return t
+}
+
+// Call invokes a runtime intrinsic, passing its arguments with param:
+//
+//	param x
+//	t = call alloc, 1
+type Call struct {
+	Op
+	fn   string
+	args []Node
+}
+
+func NewCall(fn string, p Typer, args ...Node) Call {
+	var c Call
+	c.Expr = NewExpr(NewWord(fn, ID), p)
+	c.fn = fn
+	c.args = args
+	return c
+}
+
+func (c Call) genNode(out Emitter) Node {
+	args := make([]Node, len(c.args))
+	for i, x := range c.args {
+		args[i] = x.reduce(out)
+	}
+	for _, x := range args {
+		out.Param(x)
+	}
+	return c
+}
+
+func (c Call) String() string { return fmt.Sprintf("call %s, %d", c.fn, len(c.args)) }
+func (c Call) reduce(out Emitter) Node {
+	x := c.genNode(out)
+	t := NewTemp(c.typ)
+	out.Assign(t, x)
+	return t
 }
 
 type Logical struct {
@@ -366,29 +719,26 @@ func NewLogical(tok Token, x1, x2 Node) Logical {
 	l.Expr = NewExpr(tok, nil)
 	l.expr1, l.expr2 = x1, x2
 	l.typ = l.check(x1.typer(), x2.typer())
-	if l.typ == nil {
-		l.error("type error")
-	}
 	return l
 }
 
 func (l *Logical) check(p1, p2 Typer) Typer {
-	if p1.(Type) == Bool && p2.(Type) == Bool {
+	if p1 == Bool && p2 == Bool {
 		return Bool
 	}
 	return nil
 }
 
-func (l Logical) genNode() Node {
+func (l Logical) genNode(out Emitter) Node {
 	f := newLabel()
 	a := newLabel()
 	temp := NewTemp(l.typ)
-	l.jumping(0, f)
-	emit("%s = true", temp)
-	emit("goto L%d", a)
-	emitLabel(f)
-	emit("%s = false", temp)
-	emitLabel(a)
+	l.jumping(out, 0, f)
+	out.Assign(temp, ConstantTrue)
+	out.Goto(a)
+	out.Label(f)
+	out.Assign(temp, ConstantFalse)
+	out.Label(a)
 	return temp
 }
 
@@ -400,16 +750,18 @@ type Rel struct {
 	Logical
 }
 
-func (r Rel) genNode() Node {
+func (r Rel) reduce(out Emitter) Node { return r.genNode(out) }
+
+func (r Rel) genNode(out Emitter) Node {
 	f := newLabel()
 	a := newLabel()
 	temp := NewTemp(r.typ)
-	r.jumping(0, f)
-	emit("%s = true", temp)
-	emit("goto L%d", a)
-	emitLabel(f)
-	emit("%s = false", temp)
-	emitLabel(a)
+	r.jumping(out, 0, f)
+	out.Assign(temp, ConstantTrue)
+	out.Goto(a)
+	out.Label(f)
+	out.Assign(temp, ConstantFalse)
+	out.Label(a)
 	return temp
 }
 
@@ -419,25 +771,45 @@ func NewRel(tok Token, x1, x2 Node) Rel {
 	r.expr1 = x1
 	r.expr2 = x2
 	r.typ = r.check(x1.typer(), x2.typer())
-	if r.typ == nil {
-		r.error("type error")
-	}
 	return r
 }
 
 func (l *Rel) check(p1, p2 Typer) Typer {
+	if !resolved(p1, p2) {
+		return nil
+	}
 	_, ok1 := p1.(Array)
 	_, ok2 := p2.(Array)
 	if ok1 || ok2 {
 		return nil
+	} else if IsPointerType(p1) || IsPointerType(p2) {
+		if p1 == p2 {
+			return Bool
+		}
+		return nil
+	} else if IsEnumType(p1) || IsEnumType(p2) {
+		if p1 == p2 && (l.op.Tag() == EQ || l.op.Tag() == NE) {
+			return Bool
+		}
+		return nil
+	} else if IsNumbericType(p1) && IsNumbericType(p2) {
+		return Bool
 	} else if p1.Lexeme() == p2.Lexeme() {
 		return Bool
 	}
 	return nil
 }
 
-func (r Rel) jumping(t, f int) {
-	emitjumps(fmt.Sprintf("%s %s %s", r.expr1.reduce(), r.op, r.expr2.reduce()), t, f)
+func (r Rel) jumping(out Emitter, t, f int) { out.CondJump(r.test(out), t, f) }
+
+// test reduces the operands of r and widens them to a common type.
+func (r Rel) test(out Emitter) Rel {
+	p := MaxType(r.expr1.typer(), r.expr2.typer())
+	x1 := widen(out, r.expr1.reduce(out), r.expr1.typer(), p)
+	x2 := widen(out, r.expr2.reduce(out), r.expr2.typer(), p)
+	test := r
+	test.expr1, test.expr2 = x1, x2
+	return test
 }
 
 type OrNode struct {
@@ -448,28 +820,30 @@ func NewOrNode(tok Token, x1, x2 Node) OrNode {
 	return OrNode{Logical: NewLogical(tok, x1, x2)}
 }
 
-func (o OrNode) jumping(t, f int) {
+func (o OrNode) jumping(out Emitter, t, f int) {
 	label := t
 	if label == 0 {
 		label = newLabel()
 	}
-	o.expr1.jumping(label, 0)
-	o.expr2.jumping(t, f)
+	o.expr1.jumping(out, label, 0)
+	o.expr2.jumping(out, t, f)
 	if t == 0 {
-		emitLabel(label)
+		out.Label(label)
 	}
 }
 
-func (o OrNode) genNode() Node {
+func (o OrNode) reduce(out Emitter) Node { return o.genNode(out) }
+
+func (o OrNode) genNode(out Emitter) Node {
 	f := newLabel()
 	a := newLabel()
 	temp := NewTemp(o.typ)
-	o.jumping(0, f)
-	emit("%s = true", temp)
-	emit("goto L%d", a)
-	emitLabel(f)
-	emit("%s = false", temp)
-	emitLabel(a)
+	o.jumping(out, 0, f)
+	out.Assign(temp, ConstantTrue)
+	out.Goto(a)
+	out.Label(f)
+	out.Assign(temp, ConstantFalse)
+	out.Label(a)
 	return temp
 }
 
@@ -481,29 +855,31 @@ func NewAndNode(tok Token, x1, x2 Node) AndNode {
 	return AndNode{Logical: NewLogical(tok, x1, x2)}
 }
 
-func (a AndNode) jumping(t, f int) {
+func (a AndNode) jumping(out Emitter, t, f int) {
 	label := f
 	if f == 0 {
 		label = newLabel()
 	}
 	// pretty.Println(a.expr1)
-	a.expr1.jumping(0, label)
-	a.expr2.jumping(t, f)
+	a.expr1.jumping(out, 0, label)
+	a.expr2.jumping(out, t, f)
 	if f == 0 {
-		emitLabel(label)
+		out.Label(label)
 	}
 }
 
-func (an AndNode) genNode() Node {
+func (an AndNode) reduce(out Emitter) Node { return an.genNode(out) }
+
+func (an AndNode) genNode(out Emitter) Node {
 	f := newLabel()
 	a := newLabel()
 	temp := NewTemp(an.typ)
-	an.jumping(0, f)
-	emit("%s = true", temp)
-	emit("goto L%d", a)
-	emitLabel(f)
-	emit("%s = false", temp)
-	emitLabel(a)
+	an.jumping(out, 0, f)
+	out.Assign(temp, ConstantTrue)
+	out.Goto(a)
+	out.Label(f)
+	out.Assign(temp, ConstantFalse)
+	out.Label(a)
 	return temp
 }
 
@@ -518,23 +894,33 @@ func NewArith(tok Token, x1, x2 Node) Arith {
 	a.expr1 = x1
 	a.expr2 = x2
 	a.typ = MaxType(x1.typer(), x2.typer())
-	if a.typ == nil {
-		a.error("type error")
-	}
 	return a
 }
 
-func (a Arith) genNode() Node {
+// NewPtrArith builds the address arithmetic behind p[i] and &a[i]. Users
+// cannot mix pointers and numbers, so it bypasses the MaxType check.
+func NewPtrArith(x1, x2 Node, p Typer) Arith {
+	var a Arith
+	a.Expr = NewExpr(Tag('+'), p)
+	a.expr1 = x1
+	a.expr2 = x2
+	return a
+}
+
+func (a Arith) genNode(out Emitter) Node {
 	// log.Println(a.op, a.expr1.reduce(), a.expr2.reduce())
 	// log.Printf("--> %T\n", a.expr2)
-	return NewArith(a.op, a.expr1.reduce(), a.expr2.reduce())
+	x := a
+	x.expr1 = widen(out, a.expr1.reduce(out), a.expr1.typer(), a.typ)
+	x.expr2 = widen(out, a.expr2.reduce(out), a.expr2.typer(), a.typ)
+	return x
 }
 
-func (a Arith) reduce() Node {
+func (a Arith) reduce(out Emitter) Node {
 	// debug.PrintStack()
-	x := a.genNode()
+	x := a.genNode(out)
 	t := NewTemp(a.typ)
-	emit("%s = %s", t, x)
+	out.Assign(t, x)
 	return t
 }
 
@@ -553,20 +939,67 @@ func NewUnary(tok Token, x Node) Unary {
 	u.Expr = NewExpr(tok, nil)
 	u.expr = x
 	u.typ = MaxType(Int, x.typer())
-	if u.typ == nil {
-		u.error("type error")
-	}
 	return u
 }
 
-func (u Unary) genNode() Node {
-	return NewUnary(u.Op, u.expr.reduce())
+func (u Unary) genNode(out Emitter) Node {
+	return NewUnary(u.op, widen(out, u.expr.reduce(out), u.expr.typer(), u.typ))
+}
+
+func (u Unary) reduce(out Emitter) Node {
+	x := u.genNode(out)
+	t := NewTemp(u.typ)
+	out.Assign(t, x)
+	return t
 }
 
 func (u Unary) String() string {
 	return fmt.Sprintf("%s %s", u.Op, u.expr)
 }
 
+// Cast is an explicit conversion (T) e between numeric types or between
+// pointer types.
+type Cast struct {
+	Op
+	expr Node
+}
+
+func NewCast(p Typer, x Node) Cast {
+	var c Cast
+	c.Expr = NewExpr(NewWord("cast", BASIC), p)
+	c.expr = x
+	return c
+}
+
+func (c *Cast) check(p1, p2 Typer) Typer {
+	if !resolved(p1, p2) {
+		return nil
+	}
+	if p1 == p2 {
+		return p1
+	} else if IsNumbericType(p1) && (IsNumbericType(p2) || IsEnumType(p2)) {
+		return p1
+	} else if IsPointerType(p1) && IsPointerType(p2) {
+		return p1
+	}
+	return nil
+}
+
+func (c Cast) genNode(out Emitter) Node {
+	x := c
+	x.expr = c.expr.reduce(out)
+	return x
+}
+
+func (c Cast) jumping(out Emitter, t, f int) { out.CondJump(c.reduce(out), t, f) }
+func (c Cast) String() string                { return fmt.Sprintf("(%s) %s", c.typ, c.expr) }
+func (c Cast) reduce(out Emitter) Node {
+	x := c.genNode(out)
+	t := NewTemp(c.typ)
+	out.Assign(t, x)
+	return t
+}
+
 type Not struct{ Logical }
 
 func NewNot(tok Token, x2 Node) Not {
@@ -575,24 +1008,26 @@ func NewNot(tok Token, x2 Node) Not {
 	return n
 }
 
-func (n Not) jumping(t, f int) {
-	n.expr2.jumping(f, t)
+func (n Not) jumping(out Emitter, t, f int) {
+	n.expr2.jumping(out, f, t)
 }
 
 func (n Not) String() string {
 	return fmt.Sprintf("%s %s", n.op, n.expr2)
 }
 
-func (n Not) genNode() Node {
+func (n Not) reduce(out Emitter) Node { return n.genNode(out) }
+
+func (n Not) genNode(out Emitter) Node {
 	f := newLabel()
 	a := newLabel()
 	temp := NewTemp(n.typ)
-	n.jumping(0, f)
-	emit("%s = true", temp)
-	emit("goto L%d", a)
-	emitLabel(f)
-	emit("%s = false", temp)
-	emitLabel(a)
+	n.jumping(out, 0, f)
+	out.Assign(temp, ConstantTrue)
+	out.Goto(a)
+	out.Label(f)
+	out.Assign(temp, ConstantFalse)
+	out.Label(a)
 	return temp
 }
 
@@ -601,7 +1036,7 @@ type Constant struct{ Expr }
 var ConstantTrue = NewConstant(True, Bool)
 var ConstantFalse = NewConstant(False, Bool)
 
-func NewConstant(tok Token, p Type) Constant {
+func NewConstant(tok Token, p Typer) Constant {
 	var c Constant
 	c.Expr = NewExpr(tok, p)
 	return c
@@ -611,11 +1046,13 @@ func NewConstantInt(i int) Constant {
 	return NewConstant(Num{value: i}, Int)
 }
 
-func (c Constant) jumping(t, f int) {
+func (c Constant) reduce(out Emitter) Node  { return c }
+func (c Constant) genNode(out Emitter) Node { return c }
+func (c Constant) jumping(out Emitter, t, f int) {
 	if c == ConstantTrue && t != 0 {
-		emit("goto L%d", t)
+		out.Goto(t)
 	} else if c == ConstantFalse && f != 0 {
-		emit("goto L%d", f)
+		out.Goto(f)
 	}
 }
 
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// Opcode is the kind of a quad. The comment on each opcode shows how the
+// quad reads in the printed code.
+type Opcode int
+
+const (
+	OpLabel    Opcode = iota // L:
+	OpCopy                   // result = arg1
+	OpBinary                 // result = arg1 tok arg2
+	OpUnary                  // result = tok arg1
+	OpCast                   // result = (arg2) arg1
+	OpLoad                   // result = arg1 [ arg2 ]
+	OpStore                  // result [ arg1 ] = arg2
+	OpAddr                   // result = &arg1
+	OpDeref                  // result = *arg1
+	OpSetDeref               // *result = arg1
+	OpGoto                   // goto result
+	OpIf                     // if arg1 [tok arg2] goto result
+	OpIfFalse                // iffalse arg1 [tok arg2] goto result
+	OpParam                  // param arg1
+	OpCall                   // [result =] call arg1, arg2
+)
+
+// Quad is a three-address instruction. tok is the operator of OpBinary,
+// OpUnary and of conditional jumps that compare two operands.
+type Quad struct {
+	op                 Opcode
+	tok                Token
+	arg1, arg2, result Operand
+}
+
+func (q Quad) String() string {
+	switch q.op {
+	case OpLabel:
+		return fmt.Sprintf("%s:", q.result)
+	case OpCopy:
+		return fmt.Sprintf("%s = %s", q.result, q.arg1)
+	case OpBinary:
+		return fmt.Sprintf("%s = %s %s %s", q.result, q.arg1, q.tok, q.arg2)
+	case OpUnary:
+		return fmt.Sprintf("%s = %s %s", q.result, q.tok, q.arg1)
+	case OpCast:
+		return fmt.Sprintf("%s = (%s) %s", q.result, q.arg2, q.arg1)
+	case OpLoad:
+		return fmt.Sprintf("%s = %s [ %s ]", q.result, q.arg1, q.arg2)
+	case OpStore:
+		return fmt.Sprintf("%s [ %s ] = %s", q.result, q.arg1, q.arg2)
+	case OpAddr:
+		return fmt.Sprintf("%s = &%s", q.result, q.arg1)
+	case OpDeref:
+		return fmt.Sprintf("%s = *%s", q.result, q.arg1)
+	case OpSetDeref:
+		return fmt.Sprintf("*%s = %s", q.result, q.arg1)
+	case OpGoto:
+		return fmt.Sprintf("goto %s", q.result)
+	case OpIf, OpIfFalse:
+		jump := "if"
+		if q.op == OpIfFalse {
+			jump = "iffalse"
+		}
+		if q.tok == nil {
+			return fmt.Sprintf("%s %s goto %s", jump, q.arg1, q.result)
+		}
+		return fmt.Sprintf("%s %s %s %s goto %s", jump, q.arg1, q.tok, q.arg2, q.result)
+	case OpParam:
+		return fmt.Sprintf("param %s", q.arg1)
+	case OpCall:
+		if q.result.kind == NoOperand {
+			return fmt.Sprintf("call %s, %s", q.arg1, q.arg2)
+		}
+		return fmt.Sprintf("%s = call %s, %s", q.result, q.arg1, q.arg2)
+	}
+	return fmt.Sprintf("?%d", q.op)
+}
+
+// defines returns the name that q assigns, if any.
+func (q Quad) defines() (Operand, bool) {
+	switch q.op {
+	case OpCopy, OpBinary, OpUnary, OpCast, OpLoad, OpAddr, OpDeref:
+		return q.result, true
+	case OpCall:
+		return q.result, q.result.kind != NoOperand
+	}
+	return Operand{}, false
+}
+
+// uses returns the names that q reads. A store through a pointer reads
+// the pointer; an indexed store writes part of its array and does not
+// read it.
+func (q Quad) uses() []Operand {
+	var names []Operand
+	for _, o := range []Operand{q.arg1, q.arg2} {
+		if o.isName() {
+			names = append(names, o)
+		}
+	}
+	if q.op == OpSetDeref && q.result.isName() {
+		names = append(names, q.result)
+	}
+	return names
+}
+
+// OperandKind tells what an operand of a quad names.
+type OperandKind int
+
+const (
+	NoOperand OperandKind = iota
+	VarOperand
+	TempOperand
+	ConstOperand
+	LabelOperand
+	FuncOperand // a runtime intrinsic
+	TypeOperand // the target type of a conversion
+)
+
+// Operand is an argument or the result of a quad. Operands are
+// comparable, so two operands naming the same variable are ==.
+type Operand struct {
+	kind OperandKind
+	sym  *Symbol // of a variable
+	num  int     // of a temp or a label
+	val  Token   // of a constant
+	name string  // of an intrinsic
+	typ  Typer
+	ver  int // of a name in SSA form; 0 outside it
+}
+
+func (o Operand) String() string {
+	switch o.kind {
+	case VarOperand:
+		if o.ver > 0 {
+			return fmt.Sprintf("%s.%d", o.sym.name, o.ver)
+		}
+		return o.sym.name.String()
+	case TempOperand:
+		if o.ver > 0 {
+			return fmt.Sprintf("t%d.%d", o.num, o.ver)
+		}
+		return fmt.Sprintf("t%d", o.num)
+	case ConstOperand:
+		return o.val.String()
+	case LabelOperand:
+		return fmt.Sprintf("L%d", o.num)
+	case FuncOperand:
+		return o.name
+	case TypeOperand:
+		return fmt.Sprint(o.typ)
+	}
+	return ""
+}
+
+// isName reports whether o is a variable or a temp.
+func (o Operand) isName() bool { return o.kind == VarOperand || o.kind == TempOperand }
+
+func LabelOf(i int) Operand      { return Operand{kind: LabelOperand, num: i} }
+func FuncOf(name string) Operand { return Operand{kind: FuncOperand, name: name} }
+func TypeOf(t Typer) Operand     { return Operand{kind: TypeOperand, typ: t} }
+
+// operand is the operand of a reduced expression: a variable, a temp or a
+// constant.
+func operand(x Node) Operand {
+	switch x := x.(type) {
+	case Id:
+		return Operand{kind: VarOperand, sym: x.sym, typ: x.sym.typ}
+	case Temp:
+		return Operand{kind: TempOperand, num: x.number, typ: x.typ}
+	case Constant:
+		return Operand{kind: ConstOperand, val: x.op, typ: x.typ}
+	}
+	panic(fmt.Errorf("line %d: %s is not an operand", x.Pos(), x))
+}
+
+// Emitter receives the code that gen, jumping and reduce generate. The
+// operands passed to it are reduced: variables, temps and constants.
+type Emitter interface {
+	Label(i int)
+	Goto(i int)
+	// Assign emits x = y, where y is an operand or, as genNode returns
+	// it, an operator applied to operands.
+	Assign(x, y Node)
+	// Convert emits x = (T) y, where T is the type of x.
+	Convert(x, y Node)
+	// CondJump jumps to t when test is true and to f when it is false,
+	// where 0 means fall through. test is an operand or a Rel of two
+	// operands.
+	CondJump(test Node, t, f int)
+	IndexedStore(a Id, i, y Node) // a [ i ] = y
+	Store(x, y Node)              // *x = y
+	Param(x Node)
+	// Call calls the intrinsic fn with n parameters and drops the result.
+	Call(fn string, n int)
+}
+
+// Program is the intermediate code of a compilation, in order. It is the
+// Emitter that collects the quads.
+type Program struct {
+	code []Quad
+}
+
+func (p *Program) add(q Quad) { p.code = append(p.code, q) }
+
+func (p *Program) Label(i int) {
+	if option.el {
+		debug.PrintStack()
+	}
+	p.add(Quad{op: OpLabel, result: LabelOf(i)})
+}
+
+func (p *Program) Goto(i int) {
+	p.add(Quad{op: OpGoto, result: LabelOf(i)})
+}
+
+func (p *Program) Assign(x, y Node) {
+	q := Quad{result: operand(x)}
+	switch y := y.(type) {
+	case Arith:
+		q.op, q.tok, q.arg1, q.arg2 = OpBinary, y.op, operand(y.expr1), operand(y.expr2)
+	case Unary:
+		q.op, q.tok, q.arg1 = OpUnary, y.op, operand(y.expr)
+	case Cast:
+		q.op, q.arg1, q.arg2 = OpCast, operand(y.expr), TypeOf(y.typ)
+	case Access:
+		q.op, q.arg1, q.arg2 = OpLoad, operand(y.array), operand(y.index)
+	case AddrOf:
+		q.op, q.arg1 = OpAddr, operand(y.id)
+	case Deref:
+		q.op, q.arg1 = OpDeref, operand(y.expr)
+	case Call:
+		q.op, q.arg1, q.arg2 = OpCall, FuncOf(y.fn), operand(NewConstantInt(len(y.args)))
+	default:
+		q.op, q.arg1 = OpCopy, operand(y)
+	}
+	p.add(q)
+}
+
+func (p *Program) Convert(x, y Node) {
+	p.add(Quad{op: OpCast, arg1: operand(y), arg2: TypeOf(x.typer()), result: operand(x)})
+}
+
+func (p *Program) IndexedStore(a Id, i, y Node) {
+	p.add(Quad{op: OpStore, arg1: operand(i), arg2: operand(y), result: operand(a)})
+}
+
+func (p *Program) Store(x, y Node) {
+	p.add(Quad{op: OpSetDeref, arg1: operand(y), result: operand(x)})
+}
+
+func (p *Program) Param(x Node) {
+	p.add(Quad{op: OpParam, arg1: operand(x)})
+}
+
+func (p *Program) Call(fn string, n int) {
+	p.add(Quad{op: OpCall, arg1: FuncOf(fn), arg2: operand(NewConstantInt(n))})
+}
+
+// condition is a conditional jump on test whose opcode and target are
+// left to the caller.
+func condition(test Node) Quad {
+	var q Quad
+	if r, ok := test.(Rel); ok {
+		q.tok, q.arg1, q.arg2 = r.op, operand(r.expr1), operand(r.expr2)
+	} else {
+		q.arg1 = operand(test)
+	}
+	return q
+}
+
+func (p *Program) CondJump(test Node, t, f int) {
+	q := condition(test)
+	if t != 0 && f != 0 {
+		q.op, q.result = OpIf, LabelOf(t)
+		p.add(q)
+		p.Goto(f)
+	} else if t != 0 {
+		q.op, q.result = OpIf, LabelOf(t)
+		p.add(q)
+	} else if f != 0 {
+		q.op, q.result = OpIfFalse, LabelOf(f)
+		p.add(q)
+	}
+}
+
+// print writes p in the textual format of the Dragon Book front end.
+// Labels share a line with the instruction they mark.
+func (p *Program) print(w io.Writer) {
+	for _, q := range p.code {
+		if q.op == OpLabel {
+			fmt.Fprint(w, q)
+			continue
+		}
+		fmt.Fprintf(w, "\t%s\n", q)
+	}
+	fmt.Fprintln(w)
+}
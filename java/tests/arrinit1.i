@@ -0,0 +1,38 @@
+L1:	a [ 0 ] = 1
+L5:	a [ 4 ] = 2
+L6:	a [ 8 ] = 3
+L7:	a [ 12 ] = 4
+L8:	a [ 16 ] = 5
+L4:	m [ 0 ] = 1.0
+L10:	m [ 8 ] = 2.0
+L11:	m [ 16 ] = 3.0
+L9:	m [ 24 ] = 4.0
+L12:	m [ 32 ] = 5.0
+L13:	m [ 40 ] = 6.0
+L3:	t1 = a [ 0 ]
+	b [ 0 ] = t1
+	t2 = a [ 4 ]
+	b [ 4 ] = t2
+	t3 = a [ 8 ]
+	b [ 8 ] = t3
+	t4 = a [ 12 ]
+	b [ 12 ] = t4
+	t5 = a [ 16 ]
+	b [ 16 ] = t5
+L14:	t6 = m [ 0 ]
+	n [ 0 ] = t6
+	t7 = m [ 8 ]
+	n [ 8 ] = t7
+	t8 = m [ 16 ]
+	n [ 16 ] = t8
+	t9 = m [ 24 ]
+	n [ 24 ] = t9
+	t10 = m [ 32 ]
+	n [ 32 ] = t10
+	t11 = m [ 40 ]
+	n [ 40 ] = t11
+L15:	t12 = 2 * 4
+	t13 = 4 * 4
+	t14 = a [ t13 ]
+	b [ t12 ] = t14
+L2:
@@ -0,0 +1,9 @@
+{
+	int[5] a = {1, 2, 3, 4, 5};
+	float[2][3] m = {{1.0, 2.0, 3.0}, {4.0, 5.0, 6.0}};
+	int[5] b;
+	float[2][3] n;
+	b = a;
+	n = m;
+	b[2] = a[4];
+}
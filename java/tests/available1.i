@@ -0,0 +1,93 @@
+entry	succ B1
+	in	{}
+	out	{}
+B1	pred entry	succ B2
+	in	{}
+	j = call read_int, 0
+	i = 0
+	s = 0
+	out	{}
+B2 L5:	pred B1 B7	succ B8 B3
+	in	{}
+	iffalse i < 10 goto L6
+	out	{}
+B3	pred B2	succ B8 B4
+	in	{}
+	if j == 3 goto L6
+	out	{}
+B4	pred B3	succ B8 B5
+	in	{}
+	if s > 100 goto L6
+	out	{}
+B5	pred B4	succ B7 B6
+	in	{}
+	t1 = i * 4
+	t2 = i * j
+	a [ t1 ] = t2
+	t3 = i * 4
+	t4 = a [ t3 ]
+	iffalse t4 > 50 goto L9
+	out	{i * 4, i * j, a [ t3 ]}
+B6	pred B5	succ B8
+	in	{i * 4, i * j, a [ t3 ]}
+	goto L6
+	out	{i * 4, i * j, a [ t3 ]}
+B7 L9:	pred B5	succ B2
+	in	{i * 4, i * j, a [ t3 ]}
+	t5 = i * 4
+	t6 = a [ t5 ]
+	s = s + t6
+	i = i + 1
+	goto L5
+	out	{a [ t3 ], a [ t5 ]}
+B8 L6:	pred B2 B3 B4 B6 B9	succ B10 B9
+	in	{}
+	j = j - 1
+	s = s + j
+	iffalse j > 0 goto L15
+	out	{}
+B9	pred B8	succ B8 B10
+	in	{}
+	if s != 7 goto L6
+	out	{}
+B10 L15:	pred B8 B9	succ B12 B11
+	in	{}
+	if i < j goto L19
+	out	{}
+B11	pred B10	succ B13 B12
+	in	{}
+	iffalse s >= 2 goto L17
+	out	{}
+B12 L19:	pred B10 B11	succ B14
+	in	{}
+	t7 = true
+	goto L18
+	out	{}
+B13 L17:	pred B11	succ B14
+	in	{}
+	t7 = false
+	out	{}
+B14 L18:	pred B12 B13	succ B16 B15
+	in	{}
+	b = t7
+	iffalse b goto L22
+	out	{}
+B15	pred B14	succ B17
+	in	{}
+	s = i * j
+	goto L20
+	out	{i * j}
+B16 L22:	pred B14	succ B17
+	in	{}
+	t8 = i * j
+	s = t8 + 1
+	out	{i * j, t8 + 1}
+B17 L20:	pred B15 B16	succ exit
+	in	{i * j}
+	param s
+	call print_int, 1
+	call print_newline, 0
+	out	{i * j}
+exit	pred B17
+	in	{i * j}
+	out	{i * j}
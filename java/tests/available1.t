@@ -0,0 +1,15 @@
+{
+	int i; int j; int s; bool b; int[10] a;
+	read(j);
+	i = 0; s = 0;
+	while (i < 10 && !(j == 3 || s > 100)) {
+		a[i] = i * j;
+		if (a[i] > 50) break;
+		s = s + a[i];
+		i = i + 1;
+	}
+	do { j = j - 1; s = s + j; } while (j > 0 && s != 7);
+	b = i < j || s >= 2;
+	if (b) s = i * j; else s = i * j + 1;
+	print(s);
+}
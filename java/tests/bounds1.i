@@ -0,0 +1,26 @@
+L1:	i = call read_int, 0
+L3:	if i < 0 goto L5
+	if i >= 10 goto L5
+	t1 = i * 4
+	a [ t1 ] = 1
+L4:	t2 = 3 + 4
+	t3 = t2 * 4
+	t4 = i + 1
+	if t4 < 0 goto L5
+	if t4 >= 10 goto L5
+	t5 = t4 * 4
+	t6 = a [ t5 ]
+	a [ t3 ] = t6
+L6:	if i < 0 goto L5
+	if i >= 4 goto L5
+	t7 = i * 24
+	t8 = 2 * 8
+	t9 = t7 + t8
+	b [ t9 ] = 1.5
+L7:	t10 = minus 2
+	t11 = minus t10
+	t12 = t11 * 4
+	a [ t12 ] = 0
+L2:	goto L8
+L5:	call bounds_error, 0
+L8:
@@ -0,0 +1,8 @@
+{
+	int i; int[10] a; float[4][3] b;
+	read(i);
+	a[i] = 1;
+	a[3 + 4] = a[i + 1];
+	b[i][2] = 1.5;
+	a[- -2] = 0;
+}
@@ -0,0 +1,36 @@
+	j = call read_int, 0
+	i = 0
+	s = 0
+L1:	iffalse i < 10 goto L3
+	if j == 3 goto L3
+	if s > 100 goto L3
+	t1 = i * 4
+	t2 = i * j
+	a [ t1 ] = t2
+	t3 = i * 4
+	t4 = a [ t3 ]
+	iffalse t4 > 50 goto L2
+	goto L3
+L2:	t5 = i * 4
+	t6 = a [ t5 ]
+	s = s + t6
+	i = i + 1
+	goto L1
+L3:	j = j - 1
+	s = s + j
+	iffalse j > 0 goto L4
+	if s != 7 goto L3
+L4:	if i < j goto L5
+	iffalse s >= 2 goto L6
+L5:	b = true
+	goto L7
+L6:	b = false
+L7:	iffalse b goto L8
+	s = i * j
+	goto L9
+L8:	t7 = i * j
+	s = t7 + 1
+L9:	param s
+	call print_int, 1
+	call print_newline, 0
+
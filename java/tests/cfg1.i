@@ -0,0 +1,55 @@
+entry	succ B1
+B1	pred entry	succ B2
+	j = call read_int, 0
+	i = 0
+	s = 0
+B2 L5:	pred B1 B7	succ B8 B3
+	iffalse i < 10 goto L6
+B3	pred B2	succ B8 B4
+	if j == 3 goto L6
+B4	pred B3	succ B8 B5
+	if s > 100 goto L6
+B5	pred B4	succ B7 B6
+	t1 = i * 4
+	t2 = i * j
+	a [ t1 ] = t2
+	t3 = i * 4
+	t4 = a [ t3 ]
+	iffalse t4 > 50 goto L9
+B6	pred B5	succ B8
+	goto L6
+B7 L9:	pred B5	succ B2
+	t5 = i * 4
+	t6 = a [ t5 ]
+	s = s + t6
+	i = i + 1
+	goto L5
+B8 L6:	pred B2 B3 B4 B6 B9	succ B10 B9
+	j = j - 1
+	s = s + j
+	iffalse j > 0 goto L15
+B9	pred B8	succ B8 B10
+	if s != 7 goto L6
+B10 L15:	pred B8 B9	succ B12 B11
+	if i < j goto L19
+B11	pred B10	succ B13 B12
+	iffalse s >= 2 goto L17
+B12 L19:	pred B10 B11	succ B14
+	t7 = true
+	goto L18
+B13 L17:	pred B11	succ B14
+	t7 = false
+B14 L18:	pred B12 B13	succ B16 B15
+	b = t7
+	iffalse b goto L22
+B15	pred B14	succ B17
+	s = i * j
+	goto L20
+B16 L22:	pred B14	succ B17
+	t8 = i * j
+	s = t8 + 1
+B17 L20:	pred B15 B16	succ exit
+	param s
+	call print_int, 1
+	call print_newline, 0
+exit	pred B17
@@ -0,0 +1,49 @@
+digraph cfg {
+	node [shape=box fontname=monospace]
+	entry [label="entry\l"]
+	B1 [label="B1\lj = call read_int, 0\li = 0\ls = 0\l"]
+	B2 [label="B2 L5:\liffalse i < 10 goto L6\l"]
+	B3 [label="B3\lif j == 3 goto L6\l"]
+	B4 [label="B4\lif s > 100 goto L6\l"]
+	B5 [label="B5\lt1 = i * 4\lt2 = i * j\la [ t1 ] = t2\lt3 = i * 4\lt4 = a [ t3 ]\liffalse t4 > 50 goto L9\l"]
+	B6 [label="B6\lgoto L6\l"]
+	B7 [label="B7 L9:\lt5 = i * 4\lt6 = a [ t5 ]\ls = s + t6\li = i + 1\lgoto L5\l"]
+	B8 [label="B8 L6:\lj = j - 1\ls = s + j\liffalse j > 0 goto L15\l"]
+	B9 [label="B9\lif s != 7 goto L6\l"]
+	B10 [label="B10 L15:\lif i < j goto L19\l"]
+	B11 [label="B11\liffalse s >= 2 goto L17\l"]
+	B12 [label="B12 L19:\lt7 = true\lgoto L18\l"]
+	B13 [label="B13 L17:\lt7 = false\l"]
+	B14 [label="B14 L18:\lb = t7\liffalse b goto L22\l"]
+	B15 [label="B15\ls = i * j\lgoto L20\l"]
+	B16 [label="B16 L22:\lt8 = i * j\ls = t8 + 1\l"]
+	B17 [label="B17 L20:\lparam s\lcall print_int, 1\lcall print_newline, 0\l"]
+	exit [label="exit\l"]
+	entry -> B1
+	B1 -> B2
+	B2 -> B8
+	B2 -> B3
+	B3 -> B8
+	B3 -> B4
+	B4 -> B8
+	B4 -> B5
+	B5 -> B7
+	B5 -> B6
+	B6 -> B8
+	B7 -> B2
+	B8 -> B10
+	B8 -> B9
+	B9 -> B8
+	B9 -> B10
+	B10 -> B12
+	B10 -> B11
+	B11 -> B13
+	B11 -> B12
+	B12 -> B14
+	B13 -> B14
+	B14 -> B16
+	B14 -> B15
+	B15 -> B17
+	B16 -> B17
+	B17 -> exit
+}
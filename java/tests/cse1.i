@@ -0,0 +1,26 @@
+	i = 1
+	j = 2
+	p = &k
+	t1 = 1 * 4
+	t3 = b [ t1 ]
+	t6 = t3 + t3
+	a [ t1 ] = t6
+	t7 = 1 * 2
+	k = t7 + t7
+	t9 = 2 * 4
+	t11 = a [ t1 ]
+	a [ t9 ] = t11
+	t13 = a [ t1 ]
+	t15 = a [ t9 ]
+	k = t13 + t15
+	j = t7
+	*p = 4
+	t16 = 1 * t7
+	k = k + t16
+	t18 = k + t16
+	*p = t18
+	k = k + t16
+	param k
+	call print_int, 1
+	call print_newline, 0
+
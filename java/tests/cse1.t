@@ -0,0 +1,14 @@
+{
+	int i; int j; int k; int[10] a; int[10] b; int* p;
+	i = 1; j = 2; p = &k;
+	a[i] = b[i] + b[i];
+	k = i * j + i * j;
+	a[j] = a[i];
+	k = a[i] + a[j];
+	j = i * j;
+	*p = 4;
+	k = k + i * j;
+	*p = k + i * j;
+	k = k + i * j;
+	print(k);
+}
@@ -0,0 +1,6 @@
+L1:	d = 3.14159265358979
+L3:	f = 3.1415896
+L4:	t1 = d * 0.1
+	d = t1 + f
+L5:	d = 0.3333333333333333
+L2:
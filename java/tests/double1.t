@@ -0,0 +1,7 @@
+{
+	double d; float f;
+	d = 3.14159265358979;
+	f = 3.14159;
+	d = d * 0.1 + f;
+	d = (double) 1 / 3;
+}
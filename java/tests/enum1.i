@@ -0,0 +1,12 @@
+L1:	s = 0
+L3:	n = 0
+L4:	iffalse s != 2 goto L5
+L6:	iffalse s == 0 goto L8
+L7:	s = 1
+	goto L4
+L8:	n = n + 1
+L9:	iffalse n == 3 goto L4
+L10:	s = 2
+	goto L4
+L5:	t = s
+L2:
@@ -0,0 +1,11 @@
+{
+	enum State { IDLE, RUNNING, DONE } s;
+	enum State t;
+	int n;
+	s = IDLE; n = 0;
+	while (s != DONE) {
+		if (s == IDLE) s = RUNNING;
+		else { n = n + 1; if (n == 3) s = DONE; }
+	}
+	t = s;
+}
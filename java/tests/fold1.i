@@ -0,0 +1,22 @@
+L1:	x = call read_int, 0
+L3:	y = 7
+L4:	y = x << 3
+L5:	f = 3.0
+L6:	iffalse x > 0 goto L9
+L8:	x = 1
+	goto L7
+L9:L7:	iffalse x < 3 goto L10
+L11:	goto L7
+L10:L13:	if x < 3 goto L10
+L12:	iffalse x > 1 goto L16
+L15:	y = 1
+	goto L14
+L16:	y = 2
+L14:	param x
+	call print_int, 1
+	param y
+	call print_int, 1
+	param f
+	call print_float, 1
+	call print_newline, 0
+L2:
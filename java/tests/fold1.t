@@ -0,0 +1,13 @@
+{
+	int x; int y; float f;
+	read(x);
+	y = 2 * 3 + 1;
+	y = x * 8 + 0;
+	f = 1.5 * 2.0;
+	if (x > 0) x = 1; else if (false) x = 2;
+	while (x < 3) if (false) x = 1;
+	do if (false) x = 1; while (x < 3);
+	if (true && x > 1) y = 1; else y = 2;
+	while (false) x = x + 1;
+	print(x, y, f);
+}
@@ -0,0 +1,9 @@
+name depth offset width type
+i    0     0      4     int
+c    0     4      1     char
+a    1     5      4     int
+d    1     9      8     double
+s    1     5      3     [3]char
+b    1     8      4     int
+f    1     5      32    [4]float
+frame 37
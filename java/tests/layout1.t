@@ -0,0 +1,7 @@
+{
+	int i; char c;
+	{ int a; double d; a = 1; d = 2.0; }
+	{ char[3] s; int b; b = 2; }
+	i = 0;
+	while (i < 2) { float[4] f; f[i] = 1.0; i = i + 1; }
+}
@@ -0,0 +1,93 @@
+entry	succ B1
+	in	{a}
+	out	{a}
+B1	pred entry	succ B2
+	in	{a}
+	j = call read_int, 0
+	i = 0
+	s = 0
+	out	{j, i, s, a}
+B2 L5:	pred B1 B7	succ B8 B3
+	in	{j, i, s, a}
+	iffalse i < 10 goto L6
+	out	{j, i, s, a}
+B3	pred B2	succ B8 B4
+	in	{j, i, s, a}
+	if j == 3 goto L6
+	out	{j, i, s, a}
+B4	pred B3	succ B8 B5
+	in	{j, i, s, a}
+	if s > 100 goto L6
+	out	{j, i, s, a}
+B5	pred B4	succ B7 B6
+	in	{j, i, s, a}
+	t1 = i * 4
+	t2 = i * j
+	a [ t1 ] = t2
+	t3 = i * 4
+	t4 = a [ t3 ]
+	iffalse t4 > 50 goto L9
+	out	{j, i, s, a}
+B6	pred B5	succ B8
+	in	{j, i, s}
+	goto L6
+	out	{j, i, s}
+B7 L9:	pred B5	succ B2
+	in	{j, i, s, a}
+	t5 = i * 4
+	t6 = a [ t5 ]
+	s = s + t6
+	i = i + 1
+	goto L5
+	out	{j, i, s, a}
+B8 L6:	pred B2 B3 B4 B6 B9	succ B10 B9
+	in	{j, i, s}
+	j = j - 1
+	s = s + j
+	iffalse j > 0 goto L15
+	out	{j, i, s}
+B9	pred B8	succ B8 B10
+	in	{j, i, s}
+	if s != 7 goto L6
+	out	{j, i, s}
+B10 L15:	pred B8 B9	succ B12 B11
+	in	{j, i, s}
+	if i < j goto L19
+	out	{j, i, s}
+B11	pred B10	succ B13 B12
+	in	{j, i, s}
+	iffalse s >= 2 goto L17
+	out	{j, i}
+B12 L19:	pred B10 B11	succ B14
+	in	{j, i}
+	t7 = true
+	goto L18
+	out	{j, i, t7}
+B13 L17:	pred B11	succ B14
+	in	{j, i}
+	t7 = false
+	out	{j, i, t7}
+B14 L18:	pred B12 B13	succ B16 B15
+	in	{j, i, t7}
+	b = t7
+	iffalse b goto L22
+	out	{j, i}
+B15	pred B14	succ B17
+	in	{j, i}
+	s = i * j
+	goto L20
+	out	{s}
+B16 L22:	pred B14	succ B17
+	in	{j, i}
+	t8 = i * j
+	s = t8 + 1
+	out	{s}
+B17 L20:	pred B15 B16	succ exit
+	in	{s}
+	param s
+	call print_int, 1
+	call print_newline, 0
+	out	{}
+exit	pred B17
+	in	{}
+	out	{}
@@ -0,0 +1,16 @@
+	x = 1
+	n = call read_int, 0
+L4:	t1 = x + 1
+	n = n - 1
+	if n > 0 goto L10
+	param x
+	call print_int, 1
+	call print_newline, 0
+	param t1
+	call print_int, 1
+	call print_newline, 0
+	goto L11
+L10:	y = x
+	x = t1
+	goto L4
+L11:
@@ -0,0 +1,6 @@
+{
+	int x; int y; int n;
+	x = 1; read(n);
+	do { y = x; x = x + 1; n = n - 1; } while (n > 0);
+	print(y); print(x);
+}
@@ -0,0 +1,26 @@
+	a = call read_int, 0
+	b = call read_int, 0
+	d = call read_int, 0
+	f = call read_bool, 0
+L1:	iffalse f goto L2
+	k = b
+	b = a
+	a = k
+	goto L2
+	param a
+	call print_int, 1
+	call print_newline, 0
+	goto L1
+L2:	i = 0
+L3:	iffalse d == 0 goto L4
+	k = b
+	b = a
+	a = k
+L4:	i = i + 1
+	if i < 1 goto L3
+	param a
+	call print_int, 1
+	param b
+	call print_int, 1
+	call print_newline, 0
+
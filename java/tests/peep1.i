@@ -0,0 +1,36 @@
+	j = call read_int, 0
+	i = 0
+	s = 0
+L1:	iffalse i < 10 goto L2
+	if j == 3 goto L2
+	if s > 100 goto L2
+	t1 = i * 4
+	t2 = i * j
+	a [ t1 ] = t2
+	t3 = i * 4
+	t4 = a [ t3 ]
+	if t4 > 50 goto L2
+	t5 = i * 4
+	t6 = a [ t5 ]
+	s = s + t6
+	i = i + 1
+	goto L1
+L2:	j = j - 1
+	s = s + j
+	iffalse j > 0 goto L3
+	if s != 7 goto L2
+L3:	if i < j goto L4
+	iffalse s >= 2 goto L5
+L4:	t7 = true
+	goto L6
+L5:	t7 = false
+L6:	b = t7
+	iffalse b goto L7
+	s = i * j
+	goto L8
+L7:	t8 = i * j
+	s = t8 + 1
+L8:	param s
+	call print_int, 1
+	call print_newline, 0
+
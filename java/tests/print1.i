@@ -0,0 +1,29 @@
+L1:	x = call read_int, 0
+L3:	f = call read_float, 0
+L4:	t1 = 1 * 4
+	t2 = call read_int, 0
+	a [ t1 ] = t2
+L5:	t3 = minus x
+	param t3
+	call print_int, 1
+	call print_newline, 0
+L6:	param x
+	call print_int, 1
+	t4 = f + 1.0
+	param t4
+	call print_float, 1
+	t5 = x * 4
+	t6 = a [ t5 ]
+	param t6
+	call print_int, 1
+	call print_newline, 0
+L7:	iffalse x < 10 goto L9
+	t7 = true
+	goto L10
+L9:	t7 = false
+L10:	b = t7
+L8:	param b
+	call print_bool, 1
+	call print_newline, 0
+L11:	call print_newline, 0
+L2:
@@ -0,0 +1,11 @@
+{
+	int x; float f; int[3] a; bool b;
+	read(x);
+	read(f);
+	read(a[1]);
+	print(-x);
+	print(x, f + 1.0, a[x]);
+	b = x < 10;
+	print(b);
+	print();
+}
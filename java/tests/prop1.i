@@ -0,0 +1,22 @@
+	n = call read_int, 0
+	i = 0
+	x = 4
+	p = &k
+	k = 1
+	*p = 5
+	y = k
+L9:	iffalse i < n goto L10
+	y = 4
+	k = 5
+	i = i + 4
+	goto L9
+L10:	param 4
+	call print_int, 1
+	param y
+	call print_int, 1
+	param k
+	call print_int, 1
+	param i
+	call print_int, 1
+	call print_newline, 0
+
@@ -0,0 +1,14 @@
+{
+	int i; int n; int x; int y; int k; int* p;
+	read(n);
+	i = 0; x = 4; p = &k;
+	k = 1;
+	*p = 5;
+	y = k;
+	while (i < n) {
+		y = x;
+		k = y + 1;
+		i = i + y;
+	}
+	print(x, y, k, i);
+}
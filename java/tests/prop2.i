@@ -0,0 +1,28 @@
+	a = call read_int, 0
+	b = call read_int, 0
+	d = call read_int, 0
+	f = call read_bool, 0
+L6:	iffalse f goto L7
+	k = b
+	b = a
+	a = k
+	goto L7
+	param a
+	call print_int, 1
+	call print_newline, 0
+	goto L6
+L7:	i = 0
+L13:	if d == 0 goto L18
+	goto L16
+	iffalse a == 3 goto L16
+L18:	k = b
+	b = a
+	a = k
+L16:	i = i + 1
+	if i < 1 goto L13
+	param a
+	call print_int, 1
+	param b
+	call print_int, 1
+	call print_newline, 0
+
@@ -0,0 +1,11 @@
+{
+	int a; int b; int k; int d; int i; bool f;
+	read(a); read(b); read(d); read(f);
+	while (f) { k = b; b = a; a = k; break; print(a); }
+	i = 0;
+	do {
+		if (d == 0 || false && a == 3) { k = b; b = a; a = k; }
+		i = i + 1;
+	} while (i < 1);
+	print(a, b);
+}
@@ -0,0 +1,36 @@
+L1:	p = &x
+L3:	*p = 5
+L4:	t1 = *p
+	x = t1 + 1
+L5:	pp = &p
+L6:	t2 = *pp
+	*t2 = 7
+L7:	t3 = 10 * 4
+	param t3
+	p = call alloc, 1
+L8:	t4 = 3 * 4
+	t5 = p + t4
+	*t5 = x
+L9:	t6 = x * 4
+	t7 = p + t6
+	x = *t7
+L10:	t8 = &a
+	t9 = 2 * 8
+	q = t8 + t9
+L11:	*q = 1.5
+L12:	t10 = 1 * 8
+	ps [ t10 ] = p
+L13:	t11 = 1 * 8
+	t12 = ps [ t11 ]
+	t13 = 2 * 4
+	t14 = t12 + t13
+	x = *t14
+L14:	t16 = &x
+	iffalse p == t16 goto L16
+	t15 = true
+	goto L17
+L16:	t15 = false
+L17:	b = t15
+L15:	param 4
+	p = call alloc, 1
+L2:
@@ -0,0 +1,17 @@
+{
+	int x; int* p; int** pp; float[4] a; float* q; int*[3] ps; bool b;
+	p = &x;
+	*p = 5;
+	x = *p + 1;
+	pp = &p;
+	**pp = 7;
+	p = new int[10];
+	p[3] = x;
+	x = p[x];
+	q = &a[2];
+	*q = 1.5;
+	ps[1] = p;
+	x = ps[1][2];
+	b = p == &x;
+	p = new int;
+}
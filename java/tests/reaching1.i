@@ -0,0 +1,93 @@
+entry	succ B1
+	in	{}
+	out	{}
+B1	pred entry	succ B2
+	in	{}
+d1	j = call read_int, 0
+d2	i = 0
+d3	s = 0
+	out	{d1, d2, d3}
+B2 L5:	pred B1 B7	succ B8 B3
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+	iffalse i < 10 goto L6
+	out	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+B3	pred B2	succ B8 B4
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+	if j == 3 goto L6
+	out	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+B4	pred B3	succ B8 B5
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+	if s > 100 goto L6
+	out	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+B5	pred B4	succ B7 B6
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+d4	t1 = i * 4
+d5	t2 = i * j
+	a [ t1 ] = t2
+d6	t3 = i * 4
+d7	t4 = a [ t3 ]
+	iffalse t4 > 50 goto L9
+	out	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+B6	pred B5	succ B8
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+	goto L6
+	out	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+B7 L9:	pred B5	succ B2
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11}
+d8	t5 = i * 4
+d9	t6 = a [ t5 ]
+d10	s = s + t6
+d11	i = i + 1
+	goto L5
+	out	{d1, d4, d5, d6, d7, d8, d9, d10, d11}
+B8 L6:	pred B2 B3 B4 B6 B9	succ B10 B9
+	in	{d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11, d12, d13}
+d12	j = j - 1
+d13	s = s + j
+	iffalse j > 0 goto L15
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+B9	pred B8	succ B8 B10
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+	if s != 7 goto L6
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+B10 L15:	pred B8 B9	succ B12 B11
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+	if i < j goto L19
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+B11	pred B10	succ B13 B12
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+	iffalse s >= 2 goto L17
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+B12 L19:	pred B10 B11	succ B14
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+d14	t7 = true
+	goto L18
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13, d14}
+B13 L17:	pred B11	succ B14
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13}
+d15	t7 = false
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13, d15}
+B14 L18:	pred B12 B13	succ B16 B15
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13, d14, d15}
+d16	b = t7
+	iffalse b goto L22
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13, d14, d15, d16}
+B15	pred B14	succ B17
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13, d14, d15, d16}
+d17	s = i * j
+	goto L20
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d14, d15, d16, d17}
+B16 L22:	pred B14	succ B17
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d13, d14, d15, d16}
+d18	t8 = i * j
+d19	s = t8 + 1
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d14, d15, d16, d18, d19}
+B17 L20:	pred B15 B16	succ exit
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d14, d15, d16, d17, d18, d19}
+	param s
+	call print_int, 1
+	call print_newline, 0
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d14, d15, d16, d17, d18, d19}
+exit	pred B17
+	in	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d14, d15, d16, d17, d18, d19}
+	out	{d2, d4, d5, d6, d7, d8, d9, d11, d12, d14, d15, d16, d17, d18, d19}
@@ -0,0 +1,17 @@
+	n = call read_int, 0
+	i = 1
+	j = 2
+L1:	iffalse n > 0 goto L5
+	iffalse i == 1 goto L2
+	j = j + 1
+	goto L3
+L2:	j = i * 100
+L3:	iffalse j > 1000 goto L4
+	i = 2
+L4:	n = n - 1
+	goto L1
+L5:	b = true
+	param i
+	call print_int, 1
+	call print_newline, 0
+
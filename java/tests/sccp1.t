@@ -0,0 +1,12 @@
+{
+	int i; int j; int n; bool b;
+	read(n);
+	i = 1; j = 2;
+	while (n > 0) {
+		if (i == 1) j = j + 1; else j = i * 100;
+		if (j > 1000) i = 2;
+		n = n - 1;
+	}
+	b = 1 < 2;
+	if (b) print(i); else print(j);
+}
@@ -0,0 +1,19 @@
+	a = 1
+	b = 2
+	n = 0
+L5:	iffalse n < 5 goto L6
+	n = n + 1
+	param b
+	call print_int, 1
+	call print_newline, 0
+	t1 = a
+	a = b
+	b = t1
+	goto L5
+L6:	param a
+	call print_int, 1
+	call print_newline, 0
+	param b
+	call print_int, 1
+	call print_newline, 0
+
@@ -0,0 +1,6 @@
+{
+	int a; int b; int t; int n;
+	a = 1; b = 2; n = 0;
+	while (n < 5) { t = a; a = b; b = t; n = n + 1; print(a); }
+	print(a); print(b);
+}
@@ -0,0 +1,13 @@
+L1:	i = 1
+L3:	c = 2
+L4:	t1 = i * 12
+	t2 = 2 * 4
+	t3 = t1 + t2
+	a [ t3 ] = d
+L5:	p = &i
+L6:	iffalse c < i goto L7
+	t4 = true
+	goto L8
+L7:	t4 = false
+L8:	b = t4
+L2:
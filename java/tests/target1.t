@@ -0,0 +1,7 @@
+{
+	char c; int i; double d; int* p; float[4][3] a; bool b;
+	i = 1; c = 2;
+	a[i][2] = d;
+	p = &i;
+	b = c < i;
+}
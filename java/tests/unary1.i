@@ -0,0 +1,12 @@
+L1:	c = 1
+L3:	t1 = minus c
+	x = t1 + 1
+L4:	t2 = minus c
+	x = minus t2
+L5:	t3 = minus c
+	f = t3 * 2.0
+L6:	t4 = c + 1
+	t5 = minus t4
+	t6 = minus c
+	x = t5 * t6
+L2:
@@ -0,0 +1,8 @@
+{
+	int x; int c; float f;
+	c = 1;
+	x = -c + 1;
+	x = - -c;
+	f = -c * 2.0;
+	x = -(c + 1) * -c;
+}
@@ -0,0 +1,8 @@
+name depth offset width type
+i    0     0      4     int
+a    0     4      80    [10]float
+x    0     84     4     int
+y    0     88     8     float
+b    0     96     1     bool
+e    0     97     8     float
+frame 105
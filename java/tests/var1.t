@@ -0,0 +1,10 @@
+{
+	int i; float[10] a;
+	var x = 3 + 1;
+	var y = x * 2.5;
+	var b = x < y;
+	var e = a[2];
+	i = 2;
+	x = x + i;
+	y = e;
+}
@@ -0,0 +1,8 @@
+scope 1:1 depth 0
+ name type      offset declared read             written
+ i    int       0      2:6      6:7 7:5 8:7 9:10 3:2 8:3
+ a    [10]float 4      2:19     -                7:3
+ done bool      84     2:27     4:10             3:9 9:3
+scope 4:16 depth 1
+ name type offset declared read written
+ j    int  85     5:7      7:10 6:3
@@ -0,0 +1,11 @@
+{
+	int i; float[10] a; bool done;
+	i = 0; done = false;
+	while (!done) {
+		int j;
+		j = i * 2;
+		a[i] = j;
+		i = i + 1;
+		done = i >= 10;
+	}
+}
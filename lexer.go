@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"strconv"
+	"strings"
 )
 
 type Tag int
@@ -33,6 +36,14 @@ const (
 	TEMP  Tag = 273
 	TRUE  Tag = 274
 	WHILE Tag = 275
+	NEW   Tag = 276
+	PRINT Tag = 277
+	READ  Tag = 278
+	ENUM  Tag = 279
+	VAR   Tag = 280
+
+	UNSIGNED Tag = 281
+	SHL      Tag = 282
 )
 
 func (t Tag) Tag() Tag {
@@ -81,6 +92,20 @@ func (t Tag) String() string {
 		return "true"
 	case WHILE:
 		return "while"
+	case NEW:
+		return "new"
+	case PRINT:
+		return "print"
+	case READ:
+		return "read"
+	case ENUM:
+		return "enum"
+	case VAR:
+		return "var"
+	case UNSIGNED:
+		return "unsigned"
+	case SHL:
+		return "shl"
 		// case INT:
 		// 	return "int"
 		// case FLOAT:
@@ -91,7 +116,7 @@ func (t Tag) String() string {
 		// 	return "bool"
 	}
 
-	return string(t)
+	return string(rune(t))
 }
 
 type Token interface {
@@ -101,6 +126,9 @@ type Token interface {
 
 type Lexer struct {
 	_peek byte
+	// offset counts the bytes read, lineStart is the offset of the first
+	// byte of the current line.
+	offset, lineStart int
 	// line   int
 	tokens map[string]Token
 	// *scanner.Scanner
@@ -110,6 +138,14 @@ type Lexer struct {
 
 var lexerLine = 1
 
+// lexerCol is the column of the first character of the token scanned last.
+var lexerCol int
+
+// Pos is a position in the source.
+type Pos struct{ line, col int }
+
+func (p Pos) String() string { return fmt.Sprintf("%d:%d", p.line, p.col) }
+
 func NewLexer(r io.Reader) *Lexer {
 	l := Lexer{tokens: map[string]Token{}, r: r}
 	l.reserve(&Word{lexeme: "if", tag: IF})
@@ -117,6 +153,12 @@ func NewLexer(r io.Reader) *Lexer {
 	l.reserve(&Word{lexeme: "while", tag: WHILE})
 	l.reserve(&Word{lexeme: "do", tag: DO})
 	l.reserve(&Word{lexeme: "break", tag: BREAK})
+	l.reserve(&Word{lexeme: "new", tag: NEW})
+	l.reserve(&Word{lexeme: "print", tag: PRINT})
+	l.reserve(&Word{lexeme: "read", tag: READ})
+	l.reserve(&Word{lexeme: "enum", tag: ENUM})
+	l.reserve(&Word{lexeme: "var", tag: VAR})
+	l.reserve(&Word{lexeme: "unsigned", tag: UNSIGNED})
 
 	l.reserve(True)
 	l.reserve(False)
@@ -125,6 +167,9 @@ func NewLexer(r io.Reader) *Lexer {
 	l.reserve(Char)
 	l.reserve(Bool)
 	l.reserve(Float)
+	l.reserve(Byte)
+	l.reserve(Long)
+	l.reserve(Double)
 	return &l
 }
 
@@ -143,6 +188,12 @@ func (l *Lexer) read() byte {
 	}
 	buf := make([]byte, 1)
 	_, l.err = l.r.Read(buf)
+	if l.err == nil {
+		l.offset++
+		if buf[0] == '\n' {
+			l.lineStart = l.offset
+		}
+	}
 	return buf[0]
 }
 
@@ -180,6 +231,7 @@ read:
 				return nil, l.getErr()
 			}
 		default:
+			lexerCol = l.offset - l.lineStart
 			break read
 		}
 	}
@@ -242,17 +294,24 @@ read:
 		if l.peek() != '.' {
 			return NewNum(v), nil
 		}
-		x := float64(v)
-		d := float64(10)
+		// Accumulate in single precision, as the Java front end does, so
+		// that 3.14159 reads as 3.1415896. The digits are kept for a
+		// double.
+		x := float32(v)
+		d := float32(10)
+		digits := strconv.Itoa(v) + "."
 		for {
 			l.read()
 			if !isDigit(l.peek()) {
 				break
 			}
-			x = x + float64(l.peek()-'0')/d
+			x = x + float32(l.peek()-'0')/d
 			d *= 10
+			digits += string(l.peek())
 		}
-		return NewReal(x), nil
+		r := NewReal(float64(x))
+		r.digits, _ = strconv.ParseFloat(digits, 64)
+		return r, nil
 	}
 	if isLetter(l.peek()) {
 		var b bytes.Buffer
@@ -328,6 +387,7 @@ var (
 	Ge = Word{lexeme: ">=", tag: GE}
 
 	Minus = Word{lexeme: "minus", tag: MINUS}
+	Shl   = Word{lexeme: "<<", tag: SHL} // only made by constant folding
 
 	True  = Word{lexeme: "true", tag: TRUE}
 	False = Word{lexeme: "false", tag: FALSE}
@@ -338,45 +398,96 @@ var (
 type Typer interface {
 	Lexeme() string
 	Width() int
+	Align() int
 }
 
+// Type is a basic type. Its width and alignment come from the target.
+// The numeric types form a lattice ordered by rank; an unsigned type has
+// the rank of its signed counterpart.
 type Type struct {
-	lexeme string
-	tag    Tag
-	width  int
+	lexeme   string
+	tag      Tag
+	rank     int // 0 for types that are not numeric
+	unsigned bool
 }
 
 func (t Type) Lexeme() string { return t.lexeme }
 func (t Type) String() string { return t.lexeme }
 func (t Type) Tag() Tag       { return t.tag }
-func (t Type) Width() int     { return t.width }
+func (t Type) Width() int     { return target.size(t.storage()) }
+func (t Type) Align() int     { return target.align(t.storage()) }
+
+// storage is the type whose size and alignment t shares.
+func (t Type) storage() string { return strings.TrimPrefix(t.lexeme, "unsigned ") }
 
 var (
-	Int   = Type{lexeme: "int", tag: BASIC, width: 4}
-	Float = Type{lexeme: "float", tag: BASIC, width: 8}
-	Char  = Type{lexeme: "char", tag: BASIC, width: 1}
-	Bool  = Type{lexeme: "bool", tag: BASIC, width: 1}
+	Int   = Type{lexeme: "int", tag: BASIC, rank: 3}
+	Float = Type{lexeme: "float", tag: BASIC, rank: 5}
+	Char  = Type{lexeme: "char", tag: BASIC, rank: 2}
+	Bool  = Type{lexeme: "bool", tag: BASIC}
+
+	Byte   = Type{lexeme: "byte", tag: BASIC, rank: 1}
+	Long   = Type{lexeme: "long", tag: BASIC, rank: 4}
+	Double = Type{lexeme: "double", tag: BASIC, rank: 6}
+
+	UByte = Type{lexeme: "unsigned byte", tag: BASIC, rank: 1, unsigned: true}
+	UChar = Type{lexeme: "unsigned char", tag: BASIC, rank: 2, unsigned: true}
+	UInt  = Type{lexeme: "unsigned int", tag: BASIC, rank: 3, unsigned: true}
+	ULong = Type{lexeme: "unsigned long", tag: BASIC, rank: 4, unsigned: true}
 )
 
-func IsNumbericType(t Typer) bool {
-	if t == nil {
-		return false
+// Unsigned returns the unsigned variant of the integer type t.
+func Unsigned(t Type) (Type, bool) {
+	for _, u := range []Type{UByte, UChar, UInt, ULong} {
+		if u.storage() == t.lexeme {
+			return u, true
+		}
 	}
-	return t.Lexeme() == "int" || t.Lexeme() == "float" || t.Lexeme() == "char"
+	return Type{}, false
+}
+
+func IsNumbericType(t Typer) bool {
+	p, ok := t.(Type)
+	return ok && p.rank > 0
+}
+
+func IsIntegerType(t Typer) bool {
+	return IsNumbericType(t) && t.(Type).rank < Float.rank
+}
+
+func IsUnsignedType(t Typer) bool {
+	p, ok := t.(Type)
+	return ok && p.unsigned
 }
 
+// MaxType is the join of t1 and t2 in the numeric lattice: the type of
+// higher rank, and the unsigned one of two types of equal rank.
 func MaxType(t1, t2 Typer) Typer {
 	if !IsNumbericType(t1) || !IsNumbericType(t2) {
 		return nil
 	}
-	if t1.Lexeme() == "float" || t2.Lexeme() == "float" {
-		return Float
-	}
-	if t1.Lexeme() == "int" || t2.Lexeme() == "int" {
-		return Int
+	p1, p2 := t1.(Type), t2.(Type)
+	if p1.rank > p2.rank || p1.rank == p2.rank && p1.unsigned {
+		return p1
 	}
+	return p2
+}
+
+// IsPrintable reports whether print and read have an intrinsic for t.
+func IsPrintable(t Typer) bool {
+	return IsNumbericType(t) || t == Bool
+}
+
+// intrinsic names the runtime routine that does op on values of type t,
+// for example print_unsigned_int.
+func intrinsic(op string, t Typer) string {
+	return op + "_" + strings.ReplaceAll(t.Lexeme(), " ", "_")
+}
 
-	return Char
+// IsNarrowing reports whether converting a from value to a to value can
+// lose information, as in float to int.
+func IsNarrowing(from, to Typer) bool {
+	return IsNumbericType(from) && IsNumbericType(to) && MaxType(from, to) != to
 }
 
 type Array struct {
@@ -394,18 +505,125 @@ func NewArray(sz int, p Typer) Array {
 func (a Array) Tag() Tag       { return a.tag }
 func (a Array) Lexeme() string { return a.lexeme }
 func (a Array) Width() int     { return a.width }
+func (a Array) Align() int     { return a.elem.Align() }
 func (a Array) String() string {
 	return fmt.Sprintf("[%d]%s", a.size, a.elem)
 }
 
+type Pointer struct {
+	elem   Typer
+	tag    Tag
+	lexeme string
+}
+
+func NewPointer(p Typer) Pointer {
+	return Pointer{tag: Tag('*'), lexeme: "*", elem: p}
+}
+
+func (p Pointer) Tag() Tag       { return p.tag }
+func (p Pointer) Lexeme() string { return p.lexeme }
+func (p Pointer) Width() int     { return target.size("pointer") }
+func (p Pointer) Align() int     { return target.align("pointer") }
+func (p Pointer) String() string {
+	return fmt.Sprintf("*%s", p.elem)
+}
+
+// Enum is a distinct type whose values are named constants. Two enums
+// are the same type only if they come from the same declaration.
+type Enum struct {
+	lexeme string
+	tag    Tag
+	serial int
+	line   int // of the declaration
+}
+
+var enumCount int
+
+func NewEnum(name string) Enum {
+	enumCount++
+	return Enum{lexeme: name, tag: ENUM, serial: enumCount}
+}
+
+func (e Enum) Tag() Tag       { return e.tag }
+func (e Enum) Lexeme() string { return e.lexeme }
+func (e Enum) Width() int     { return Int.Width() }
+func (e Enum) Align() int     { return Int.Align() }
+func (e Enum) String() string { return "enum " + e.lexeme }
+
+func IsEnumType(t Typer) bool {
+	_, ok := t.(Enum)
+	return ok
+}
+
+func IsPointerType(t Typer) bool {
+	_, ok := t.(Pointer)
+	return ok
+}
+
+func IsArrayType(t Typer) bool {
+	_, ok := t.(Array)
+	return ok
+}
+
 type Env struct {
-	prev  *Env
-	table map[Token]Id
+	prev   *Env
+	table  map[Token]Id
+	consts map[Token]Constant
+	enums  map[Token]Enum
+	order  []Token // variables in declaration order
+	depth  int
+	pos    Pos // of the opening brace
+}
+
+func NewEnv(prev *Env) *Env {
+	e := &Env{table: map[Token]Id{}, consts: map[Token]Constant{}, enums: map[Token]Enum{}, prev: prev}
+	if prev != nil {
+		e.depth = prev.depth + 1
+	}
+	return e
+}
+
+func (e *Env) put(k Token, v Id) {
+	if _, ok := e.table[k]; !ok {
+		e.order = append(e.order, k)
+	}
+	e.table[k] = v
 }
 
-func NewEnv(prev *Env) *Env { return &Env{table: map[Token]Id{}, prev: prev} }
+func (e *Env) putConst(k Token, c Constant) { e.consts[k] = c }
+func (e *Env) putEnum(k Token, t Enum)      { e.enums[k] = t }
+
+// local finds the variable or enumerator named k in this scope only.
+func (e *Env) local(k Token) (Node, bool) {
+	if id, ok := e.table[k]; ok {
+		return id, true
+	} else if c, ok := e.consts[k]; ok {
+		return c, true
+	}
+	return nil, false
+}
+
+// lookup finds the innermost variable or enumerator named k.
+func (e *Env) lookup(k Token) (Node, bool) {
+	for env := e; env != nil; env = env.prev {
+		if id, ok := env.table[k]; ok {
+			return id, true
+		} else if c, ok := env.consts[k]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+func (e *Env) getEnum(k Token) (Enum, bool) {
+	for env := e; env != nil; env = env.prev {
+		if t, ok := env.enums[k]; ok {
+			return t, true
+		}
+	}
+	return Enum{}, false
+}
 
-func (e *Env) put(k Token, v Id) { e.table[k] = v }
 func (e *Env) get(k Token) (Id, bool) {
 	for env := e; env != nil; env = env.prev {
 		if id, ok := env.table[k]; ok {
@@ -426,12 +644,46 @@ func NewNum(v int) Num {
 func (n Num) Tag() Tag       { return NUM }
 func (n Num) String() string { return fmt.Sprint(n.value) }
 
+// Real is a floating point number: a float, in single precision, unless
+// double is set. A literal also keeps its digits in double precision, for
+// when it is used as a double.
 type Real struct {
-	value float64
+	value  float64
+	digits float64
+	double bool
 }
 
 func NewReal(v float64) Real {
-	return Real{value: v}
+	return Real{value: v, digits: v}
+}
+
+func NewDouble(v float64) Real {
+	return Real{value: v, digits: v, double: true}
+}
+
+func (r Real) Tag() Tag { return REAL }
+
+// String formats r the way Java's Float.toString does: 0.0, 3.1415896,
+// and 1.0E10 outside [1e-3, 1e7). A double is printed with as many
+// digits as it takes in double precision.
+func (r Real) String() string {
+	bits := 32
+	if r.double {
+		bits = 64
+	}
+	if a := math.Abs(r.value); a == 0 || a >= 1e-3 && a < 1e7 {
+		s := strconv.FormatFloat(r.value, 'f', -1, bits)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(r.value, 'E', -1, bits)
+	i := strings.Index(s, "E")
+	m, e := s[:i], s[i+1:]
+	if !strings.Contains(m, ".") {
+		m += ".0"
+	}
+	exp, _ := strconv.Atoi(e)
+	return fmt.Sprintf("%sE%d", m, exp)
 }
-func (r Real) Tag() Tag       { return REAL }
-func (r Real) String() string { return fmt.Sprint(r.value) }
@@ -4,16 +4,35 @@ import (
 	"log"
 
 	"flag"
-	"fmt"
 	"os"
 )
 
 var option struct {
-	lr     bool
-	pmatch bool
-	file   string
-	el     bool
-	ps     bool
+	lr       bool
+	pmatch   bool
+	file     string
+	el       bool
+	ps       bool
+	widen    bool
+	check    bool
+	wshadow  bool
+	wunused  bool
+	wuninit  bool
+	bounds   bool
+	target   string
+	layout   bool
+	xref     bool
+	output   string
+	bp       bool
+	emit     string
+	peephole bool
+	fold     bool
+	cse      bool
+	analyze  string
+	prop     bool
+	sccp     bool
+	ssa      bool
+	O1, O2   bool
 }
 
 func init() {
@@ -22,11 +41,53 @@ func init() {
 	flag.BoolVar(&option.pmatch, "pm", false, "log parser match")
 	flag.BoolVar(&option.el, "el", false, "log emitLabel")
 	flag.BoolVar(&option.ps, "ps", false, "print program block")
+	flag.BoolVar(&option.widen, "widen", false, "emit explicit type conversions")
+	flag.BoolVar(&option.check, "check", false, "stop after semantic analysis")
+	flag.BoolVar(&option.wshadow, "wshadow", false, "warn about declarations that shadow outer ones")
+	flag.BoolVar(&option.wunused, "wunused", false, "warn about unused variables")
+	flag.BoolVar(&option.wuninit, "wuninit", false, "warn about variables that may be used before initialization")
+	flag.BoolVar(&option.bounds, "bounds", false, "check array indexes at run time")
+	flag.StringVar(&option.target, "target", "dragon", "data model: dragon, ilp32, lp64 or a JSON file")
+	flag.BoolVar(&option.bp, "bp", false, "generate code in one pass with backpatching")
+	flag.BoolVar(&option.fold, "fold", false, "fold constant expressions and simplify identities")
+	flag.BoolVar(&option.cse, "cse", false, "eliminate common subexpressions within basic blocks")
+	flag.BoolVar(&option.prop, "prop", false, "propagate constants and copies across basic blocks")
+	flag.BoolVar(&option.sccp, "sccp", false, "propagate constants along executable paths and delete unreachable code")
+	flag.BoolVar(&option.ssa, "ssa", false, "go through SSA form, folding copies, and back")
+	flag.BoolVar(&option.O1, "O1", false, "optimize: -fold -cse -prop -peephole")
+	flag.BoolVar(&option.O2, "O2", false, "optimize more: -O1 -sccp")
+	flag.BoolVar(&option.peephole, "peephole", false, "remove redundant labels and jumps")
+	flag.StringVar(&option.emit, "emit", "code", "what to print: code, cfg, cfg-dot, dag or ssa")
+	flag.StringVar(&option.analyze, "analyze", "", "print the solution of a data-flow analysis: reaching, liveness or available")
+	flag.BoolVar(&option.layout, "layout", false, "print the frame layout instead of code")
+	flag.BoolVar(&option.xref, "xref", false, "print a cross-reference listing instead of code")
 	flag.StringVar(&option.file, "file", "", "test file")
+	flag.StringVar(&option.output, "o", "", "write the output to this file instead of stdout")
 }
 
 func main() {
 	flag.Parse()
+	if option.O2 {
+		option.O1, option.sccp = true, true
+	}
+	if option.O1 {
+		option.fold, option.cse, option.prop, option.peephole = true, true, true, true
+	}
+	switch option.emit {
+	case "code", "cfg", "cfg-dot", "dag", "ssa":
+	default:
+		log.Fatalf("unknown -emit %s", option.emit)
+	}
+	switch option.analyze {
+	case "", "reaching", "liveness", "available":
+	default:
+		log.Fatalf("unknown -analyze %s", option.analyze)
+	}
+	t, err := LoadTarget(option.target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	target = t
 	var lex *Lexer
 	if option.file == "" {
 		lex = NewLexer(os.Stdin)
@@ -37,7 +98,15 @@ func main() {
 		}
 		lex = NewLexer(f)
 	}
+	out := os.Stdout
+	if option.output != "" {
+		f, err := os.Create(option.output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
 	parser := NewParser(lex)
-	parser.program()
-	fmt.Println()
+	parser.program(out)
 }
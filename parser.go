@@ -9,11 +9,13 @@ import (
 )
 
 type Parser struct {
-	lexer *Lexer
-	look  Token
-	top   *Env
-	used  int
-	err   error
+	lexer   *Lexer
+	look    Token
+	top     *Env
+	used    int // first free byte of the frame
+	frame   int // frame size: the most bytes ever used
+	symbols []*Symbol
+	err     error
 }
 
 func NewParser(l *Lexer) Parser {
@@ -50,49 +52,312 @@ func (p *Parser) match(tag Tag) bool {
 	return false
 }
 
-func (p *Parser) program() {
+// program compiles the program and writes the code, or the listing the
+// options ask for, to w.
+func (p *Parser) program(w io.Writer) {
 	s := p.block()
+	s = Checker{}.stmt(s)
+	if errorCount > 0 {
+		os.Exit(1)
+	}
+	if option.wuninit {
+		NewInitChecker().stmt(s, assigned{})
+	}
+	if option.fold {
+		s = Folder{}.stmt(s)
+		if errorCount > 0 {
+			os.Exit(1)
+		}
+	}
+	if option.check {
+		return
+	}
+	if option.layout {
+		p.layout(w)
+		return
+	}
+	if option.xref {
+		p.xref(w)
+		return
+	}
 	// if option.ps {
 	// }
 	// pretty.Println(s)
-	begin, after := newLabel(), newLabel()
-	emitLabel(begin)
-	s.gen(begin, after)
-	emitLabel(after)
+	var code *Program
+	if option.bp {
+		code = Backpatch(s)
+	} else {
+		code = &Program{}
+		begin, after := newLabel(), newLabel()
+		code.Label(begin)
+		if s != nil {
+			s.gen(code, begin, after)
+		}
+		code.Label(after)
+		emitBoundsHandler(code)
+	}
+	if option.cse {
+		g := NewCFG(code)
+		g.cse()
+		code = g.program()
+	}
+	if option.sccp {
+		code = NewCFG(code).sccp()
+	}
+	if option.prop {
+		g := NewCFG(code)
+		g.propagate()
+		code = g.program()
+	}
+	if option.ssa {
+		s := NewSSA(NewCFG(code))
+		s.foldCopies()
+		code = s.program()
+	}
+	if option.peephole {
+		code.peephole()
+	}
+	if option.analyze != "" {
+		NewCFG(code).analyze(w, option.analyze)
+		return
+	}
+	switch option.emit {
+	case "cfg":
+		NewCFG(code).print(w)
+	case "cfg-dot":
+		NewCFG(code).dot(w)
+	case "dag":
+		NewCFG(code).dags(w)
+	case "ssa":
+		NewSSA(NewCFG(code)).print(w)
+	default:
+		code.print(w)
+	}
 }
 
 func (p *Parser) block() Node {
+	pos := p.pos()
 	p.match('{')
-	savedEnv := p.top
+	savedEnv, savedUsed := p.top, p.used
 	p.top = NewEnv(p.top)
-	p.decls()
+	p.top.pos = pos
+	inits := p.decls()
 	s := p.stmts()
+	if inits != nil {
+		s = NewSeq(inits, s)
+	}
 	p.match('}')
-	p.top = savedEnv
+	if option.wunused {
+		p.unused()
+	}
+	// The storage of the block is free again for its siblings.
+	p.top, p.used = savedEnv, savedUsed
 	return s
 }
 
-func (p *Parser) decls() {
-	for p.look.Tag() == BASIC {
+// unused warns about the variables of the current block that are never
+// read, even if they are assigned.
+func (p *Parser) unused() {
+	for _, tok := range p.top.order {
+		if id := p.top.table[tok]; !id.sym.used() {
+			id.warn(fmt.Sprintf("%s declared but not used", tok))
+		}
+	}
+}
+
+// D -> T id ; | T id = I ; | var id = E ;
+func (p *Parser) decls() Node {
+	var inits []Node
+	for p.look.Tag() == BASIC || p.look.Tag() == UNSIGNED || p.look.Tag() == ENUM || p.look.Tag() == VAR {
+		if p.look.Tag() == VAR {
+			inits = append(inits, p.inferred())
+			continue
+		}
 		typ := p.typ()
-		tok := p.look
+		if p.look.Tag() == ';' && IsEnumType(typ) { // D -> enum id { id, ... } ;
+			p.move()
+			continue
+		}
+		tok, pos := p.look, p.pos()
 		p.match(ID)
+		id := NewId(tok, typ, p.place(typ))
+		id.sym.pos = pos
+		if p.look.Tag() == '=' {
+			p.move()
+			id.sym.writes = append(id.sym.writes, pos)
+			inits = append(inits, p.initializer(id))
+		}
 		p.match(';')
-		id := Id{Expr: NewExpr(tok, typ), offset: p.used}
-		p.top.put(tok, id)
-		p.used += typ.Width()
+		p.declare(tok, id)
+	}
+	return seq(inits)
+}
+
+// inferred parses var id = E ; where id takes the type of E: MaxType for
+// arithmetic, bool for comparisons and the element type for an Access.
+func (p *Parser) inferred() Node {
+	p.match(VAR)
+	tok, pos := p.look, p.pos()
+	p.match(ID)
+	p.match('=')
+	x := p.bool()
+	id := NewId(tok, x.typer(), p.place(x.typer()))
+	id.sym.pos = pos
+	id.sym.writes = append(id.sym.writes, pos)
+	s := p.store(id, x)
+	p.match(';')
+	p.declare(tok, id)
+	return s
+}
+
+func (p *Parser) declare(tok Token, id Id) {
+	p.redeclared(tok, id.Line)
+	p.top.put(tok, id)
+	id.sym.scope, id.sym.depth = p.top, p.top.depth
+	p.symbols = append(p.symbols, id.sym)
+	if id.typ != nil {
+		p.used = id.offset + id.typ.Width()
+		p.frame = max(p.frame, p.used)
+	}
+}
+
+// place returns the offset of a new variable of type typ: the first free
+// byte of the frame, rounded up to the alignment of typ on the target.
+func (p *Parser) place(typ Typer) int {
+	if typ == nil {
+		return p.used
+	}
+	a := typ.Align()
+	return (p.used + a - 1) / a * a
+}
+
+// I -> E | { I, ... }
+func (p *Parser) initializer(id Id) Node {
+	if p.look.Tag() != '{' {
+		return p.store(id, p.bool())
+	}
+	typ, ok := id.typ.(Array)
+	if !ok {
+		p.error(fmt.Errorf("line %d: initializer list for %s of type %s", lexerLine, id, id.typ))
+	}
+	return p.elements(id, typ, 0)
+}
+
+// elements parses the initializer list of an array of type typ stored
+// off bytes into id. Every element becomes a SetElem with a constant
+// offset; nested lists initialize the rows of multi-dimensional arrays.
+func (p *Parser) elements(id Id, typ Array, off int) Node {
+	var inits []Node
+	p.match('{')
+	for {
+		if len(inits) == typ.size {
+			p.error(fmt.Errorf("line %d: too many initializers for %s", lexerLine, typ))
+		}
+		at := off + len(inits)*typ.elem.Width()
+		if elem, ok := typ.elem.(Array); ok {
+			inits = append(inits, p.elements(id, elem, at))
+		} else {
+			x := NewAccess(id, NewConstantInt(at), typ.elem)
+			inits = append(inits, NewSetElem(x, p.bool()))
+		}
+		if p.look.Tag() != ',' {
+			break
+		}
+		p.match(',')
+	}
+	p.match('}')
+	if len(inits) != typ.size {
+		p.error(fmt.Errorf("line %d: %s initialized with %d elements", lexerLine, typ, len(inits)))
 	}
+	return seq(inits)
+}
+
+// seq chains statements the way stmts does.
+func seq(list []Node) Node {
+	var s Node
+	for i := len(list) - 1; i >= 0; i-- {
+		s = NewSeq(list[i], s)
+	}
+	return s
 }
 
 func (p *Parser) typ() Typer {
-	typ := p.look.(Type)
-	p.match(BASIC)
+	typ := p.base()
 	if p.look.Tag() != '[' {
 		return typ
 	}
 	return p.dims(typ)
 }
 
+// T -> basic | unsigned | unsigned basic | enum | T *
+func (p *Parser) base() Typer {
+	var typ Typer
+	if p.look.Tag() == ENUM {
+		typ = p.enum()
+	} else if p.look.Tag() == UNSIGNED {
+		p.move()
+		typ = UInt
+		if t, ok := p.look.(Type); ok {
+			p.match(BASIC)
+			if typ, ok = Unsigned(t); !ok {
+				p.error(fmt.Errorf("line %d: no unsigned %s", lexerLine, t))
+			}
+		}
+	} else {
+		typ = p.look.(Type)
+		p.match(BASIC)
+	}
+	for p.look.Tag() == '*' {
+		p.move()
+		typ = NewPointer(typ)
+	}
+	return typ
+}
+
+// enum -> enum id { id, ... } | enum id
+func (p *Parser) enum() Typer {
+	p.match(ENUM)
+	tok := p.look
+	p.match(ID)
+	if p.look.Tag() != '{' {
+		typ, ok := p.top.getEnum(tok)
+		if !ok {
+			p.error(fmt.Errorf("line %d: enum %s undeclared", lexerLine, tok))
+		}
+		return typ
+	}
+	if prev, ok := p.top.enums[tok]; ok {
+		p.NewLine().errorf("%s redeclared in this block (previous declaration at line %d)", prev, prev.line)
+	}
+	typ := NewEnum(tok.String())
+	typ.line = lexerLine
+	p.top.putEnum(tok, typ)
+	p.match('{')
+	for i := 0; ; i++ {
+		name := p.look
+		p.match(ID)
+		c := NewConstant(NewNum(i), typ)
+		p.redeclared(name, c.Line)
+		p.top.putConst(name, c)
+		if p.look.Tag() != ',' {
+			break
+		}
+		p.match(',')
+	}
+	p.match('}')
+	return typ
+}
+
+// redeclared reports a name declared twice in the same block and, with
+// -wshadow, a name that hides a declaration of an enclosing block.
+func (p *Parser) redeclared(tok Token, l Line) {
+	if prev, ok := p.top.local(tok); ok {
+		l.errorf("%s redeclared in this block (previous declaration at line %d)", tok, prev.Pos())
+	} else if prev, ok := p.top.prev.lookup(tok); ok && option.wshadow {
+		l.warn(fmt.Sprintf("declaration of %s shadows declaration at line %d", tok, prev.Pos()))
+	}
+}
+
 func (p *Parser) dims(typ Typer) Typer {
 	p.match('[')
 	tok := p.look
@@ -122,6 +387,7 @@ func (p *Parser) stmt() Node {
 		p.move()
 		return nil
 	case IF:
+		line := p.NewLine()
 		p.match(IF)
 		p.match('(')
 		x = p.bool()
@@ -130,13 +396,14 @@ func (p *Parser) stmt() Node {
 		// log.Printf("--> %+v\n", p.look.Tag().Tag())
 		// log.Printf("--> %+v\n", p.look.Tag().Tag() == ELSE)
 		if p.look.Tag().Tag() != ELSE {
-			return If{expr: x, stmt: s1, Stmt: Stmt{Line: p.NewLine()}}
+			return If{expr: x, stmt: s1, Stmt: Stmt{Line: line}}
 		}
 		p.match(ELSE)
 		s2 = p.stmt()
-		return Else{expr: x, stmt1: s1, stmt2: s2, Stmt: Stmt{Line: p.NewLine()}}
+		return Else{expr: x, stmt1: s1, stmt2: s2, Stmt: Stmt{Line: line}}
 	case WHILE:
 		var while While
+		while.Line = p.NewLine()
 		savedStmt = StmtEnclosing
 		StmtEnclosing = &while
 		p.match(WHILE)
@@ -150,8 +417,9 @@ func (p *Parser) stmt() Node {
 		return &while
 	case DO:
 		var do Do
+		do.Line = p.NewLine()
 		savedStmt = StmtEnclosing
-		StmtEnclosing = do
+		StmtEnclosing = &do
 		p.match(DO)
 		s1 = p.stmt()
 		p.match(WHILE)
@@ -161,7 +429,13 @@ func (p *Parser) stmt() Node {
 		p.match(';')
 		do.init(s1, x)
 		StmtEnclosing = savedStmt
-		return do
+		return &do
+	case '*':
+		return p.assign()
+	case PRINT:
+		return p.print()
+	case READ:
+		return p.read()
 	case BREAK:
 		p.match(BREAK)
 		p.match(';')
@@ -180,25 +454,83 @@ func (p *Parser) stmt() Node {
 
 func (p *Parser) NewLine() Line { return Line(lexerLine) }
 
+// pos is the position of the lookahead token.
+func (p *Parser) pos() Pos { return Pos{lexerLine, lexerCol} }
+
 func (p *Parser) assign() Node {
-	var stmt Node
-	t := p.look
+	x := p.lvalue()
+	p.match('=')
+	stmt := p.store(x, p.bool())
+	p.match(';')
+	return stmt
+}
+
+// L -> id | L [ E ] | * E
+func (p *Parser) lvalue() Node {
+	if p.look.Tag() == '*' {
+		return p.unary()
+	}
+	t, pos := p.look, p.pos()
 	// log.Printf("--> %T %[1]#v\n", p.look)
 	p.match(ID)
 	id, ok := p.top.get(t)
 	if !ok {
 		p.error(fmt.Errorf("line %d: %s undeclared", lexerLine, t.(Word).lexeme))
 	}
-	if p.look.Tag() == '=' { // S -> id = E ;
-		p.move()
-		stmt = NewSet(id, p.bool())
-	} else { // S -> L = E ;
-		x := p.offset(id)
-		p.match('=')
-		stmt = NewSetElem(x, p.bool())
+	id.sym.writes = append(id.sym.writes, pos)
+	return p.selectors(id)
+}
+
+func (p *Parser) store(x, y Node) Node {
+	if IsArrayType(x.typer()) {
+		return NewArrayCopy(x, y)
+	}
+	switch x := x.(type) {
+	case Id: // S -> id = E ;
+		return NewSet(x, y)
+	case Access: // S -> L = E ;
+		return NewSetElem(x, y)
+	case Deref: // S -> * E = E ;
+		return NewSetDeref(x, y)
+	}
+	p.error(fmt.Errorf("line %d: cannot assign to %s", lexerLine, x))
+	return nil
+}
+
+// S -> print ( E, ... ) ;
+func (p *Parser) print() Node {
+	var args []Node
+	p.match(PRINT)
+	p.match('(')
+	for p.look.Tag() != ')' {
+		args = append(args, p.bool())
+		if p.look.Tag() != ',' {
+			break
+		}
+		p.match(',')
 	}
+	p.match(')')
+	pr := NewPrint(args)
 	p.match(';')
-	return stmt
+	return pr
+}
+
+// S -> read ( L ) ;
+func (p *Parser) read() Node {
+	p.match(READ)
+	p.match('(')
+	x := p.lvalue()
+	p.match(')')
+	var s Node
+	if typ := x.typer(); IsPrintable(typ) {
+		s = p.store(x, NewCall(intrinsic("read", typ), typ))
+	} else if typ != nil {
+		p.NewLine().errorf("type error: cannot read %s", typ)
+	} else {
+		s = p.store(x, NewCall("read", nil)) // reported by the checker
+	}
+	p.match(';')
+	return s
 }
 
 func (p *Parser) bool() Node {
@@ -273,15 +605,40 @@ func (p *Parser) unary() Node {
 		p.move()
 		x := p.unary()
 		return NewNot(tok, x)
+	} else if p.look.Tag() == '*' {
+		p.move()
+		return NewDeref(p.unary())
+	} else if p.look.Tag() == '&' {
+		p.move()
+		return p.addr(p.unary())
 	}
 	return p.factor()
 }
 
+// addr takes the address of an lvalue: &id, &a[i] or &*p.
+func (p *Parser) addr(x Node) Node {
+	switch x := x.(type) {
+	case Id:
+		return NewAddrOf(x)
+	case Access:
+		return NewPtrArith(NewAddrOf(x.array), x.index, NewPointer(x.typ))
+	case Deref:
+		return x.expr
+	}
+	p.error(fmt.Errorf("line %d: cannot take the address of %s", lexerLine, x))
+	return nil
+}
+
 func (p *Parser) factor() Node {
 	var x Node
 	switch p.look.Tag() {
 	case '(':
 		p.move()
+		if p.look.Tag() == BASIC || p.look.Tag() == UNSIGNED { // E -> ( T ) E
+			typ := p.base()
+			p.match(')')
+			return NewCast(typ, p.unary())
+		}
 		x = p.bool()
 		p.match(')')
 	case NUM:
@@ -296,17 +653,22 @@ func (p *Parser) factor() Node {
 	case FALSE:
 		x = ConstantFalse
 		p.move()
+	case NEW:
+		x = p.alloc()
 	case ID:
 		s := p.look.String()
-		id, ok := p.top.get(p.look)
+		x, ok := p.top.lookup(p.look)
 		if !ok {
 			p.error(fmt.Errorf("%s undeclared", s))
 		}
+		if id, ok := x.(Id); ok {
+			id.sym.reads = append(id.sym.reads, p.pos())
+		}
 		p.move()
 		if p.look.Tag() != '[' {
-			return id
+			return x
 		}
-		return p.offset(id)
+		return p.selectors(x)
 	default:
 		p.error(fmt.Errorf("syntax error"))
 	}
@@ -314,20 +676,67 @@ func (p *Parser) factor() Node {
 	return x
 }
 
+// alloc parses new T and new T [ E ]; both call the runtime allocator
+// with the size in bytes.
+func (p *Parser) alloc() Node {
+	p.match(NEW)
+	typ := p.base()
+	size := Node(NewConstantInt(typ.Width()))
+	if p.look.Tag() == '[' {
+		p.match('[')
+		n := p.bool()
+		p.match(']')
+		if n.typer() != Int && n.typer() != Char {
+			p.NewLine().errorf("type error: array size must be an integer, got %s", n.typer())
+		}
+		size = NewArith(Tag('*'), n, size)
+	}
+	return NewCall("alloc", NewPointer(typ), size)
+}
+
+// selectors parses the indexes following an identifier. Consecutive
+// array dimensions fold into one Access; indexing a pointer is p[i] =
+// *(p + i * w).
+func (p *Parser) selectors(x Node) Node {
+	for p.look.Tag() == '[' {
+		switch typ := x.typer().(type) {
+		case Array:
+			if d, ok := x.(Deref); ok {
+				x = p.index(d.expr, typ.elem)
+			} else {
+				x = p.offset(x.(Id))
+			}
+		case Pointer:
+			x = p.index(x, typ.elem)
+		default:
+			p.error(fmt.Errorf("line %d: cannot index %s of type %s", lexerLine, x, typ))
+		}
+	}
+	return x
+}
+
+func (p *Parser) index(ptr Node, elem Typer) Deref {
+	p.match('[')
+	i := p.bool()
+	p.match(']')
+	loc := NewArith(Tag('*'), i, NewConstantInt(elem.Width()))
+	return NewDeref(NewPtrArith(ptr, loc, NewPointer(elem)))
+}
+
 // I -> [E] | [E] I
 func (p *Parser) offset(a Id) Access {
 	var i, w, t1, t2, loc Node
 	typ := a.typ
 	p.match('[')
-	i = p.bool()
+	i = p.bound(a, p.bool(), typ.(Array))
 	p.match(']')
 	typ = typ.(Array).elem
 	w = NewConstantInt(typ.Width())
 	t1 = NewArith(Tag('*'), i, w)
 	loc = t1
-	for p.look == Tag('[') { // multi-dimensional I -> [ E ] I
+	for p.look == Tag('[') && IsArrayType(typ) { // multi-dimensional I -> [ E ] I
 		p.match('[')
-		i = p.bool()
+		i = p.bound(a, p.bool(), typ.(Array))
 		p.match(']')
 		typ = typ.(Array).elem
 		w = NewConstantInt(typ.Width())
@@ -337,3 +746,19 @@ func (p *Parser) offset(a Id) Access {
 	}
 	return NewAccess(a, loc, typ)
 }
+
+// bound checks index i into a dimension of type typ of array a: an
+// index whose value is known now, any other index at run time with
+// -bounds.
+func (p *Parser) bound(a Id, i Node, typ Array) Node {
+	if c, ok := constantIndex(i); ok {
+		inRange(p.NewLine(), a, c, typ)
+		return i
+	} else if _, ok := i.(Constant); ok {
+		return i // not an integer, reported by the checker
+	}
+	if option.bounds {
+		return NewBound(a, i, typ)
+	}
+	return i
+}
@@ -0,0 +1,160 @@
+package main
+
+// peephole cleans up the jumps and labels of p until nothing changes:
+//
+//   - a jump to a goto goes straight to the goto's target,
+//   - of the labels that mark one instruction, jumps use only the first,
+//   - if c goto L1; goto L2; L1: becomes iffalse c goto L2; L1:,
+//   - a jump to the label right after it is deleted,
+//   - a label that nothing jumps to is deleted.
+//
+// The remaining labels are then renumbered densely in program order.
+func (p *Program) peephole() {
+	for changed := true; changed; {
+		changed = p.thread() || p.mergeLabels() || p.flip() || p.jumpsToNext() || p.unusedLabels()
+	}
+	p.renumber()
+}
+
+// labels maps every label to the index of its quad.
+func (p *Program) labels() map[int]int {
+	at := map[int]int{}
+	for i, q := range p.code {
+		if q.op == OpLabel {
+			at[q.result.num] = i
+		}
+	}
+	return at
+}
+
+// follows reports whether label l marks the instruction after i, that is
+// whether it is among the labels that directly follow quad i.
+func (p *Program) follows(i, l int) bool {
+	for j := i + 1; j < len(p.code) && p.code[j].op == OpLabel; j++ {
+		if p.code[j].result.num == l {
+			return true
+		}
+	}
+	return false
+}
+
+// instr is the index of the first instruction at or after i.
+func (p *Program) instr(i int) int {
+	for i < len(p.code) && p.code[i].op == OpLabel {
+		i++
+	}
+	return i
+}
+
+func (p *Program) thread() bool {
+	at := p.labels()
+	changed := false
+	for i, q := range p.code {
+		if !isJump(q.op) {
+			continue
+		}
+		l := q.result.num
+		seen := map[int]bool{l: true}
+		for {
+			j := p.instr(at[l])
+			if j == len(p.code) || p.code[j].op != OpGoto || seen[p.code[j].result.num] {
+				break
+			}
+			l = p.code[j].result.num
+			seen[l] = true
+		}
+		if l != q.result.num {
+			p.code[i].result = LabelOf(l)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Program) mergeLabels() bool {
+	first := map[int]int{}
+	for i, q := range p.code {
+		if q.op == OpLabel {
+			first[q.result.num] = q.result.num
+			if i > 0 && p.code[i-1].op == OpLabel {
+				first[q.result.num] = first[p.code[i-1].result.num]
+			}
+		}
+	}
+	changed := false
+	for i, q := range p.code {
+		if isJump(q.op) && first[q.result.num] != q.result.num {
+			p.code[i].result = LabelOf(first[q.result.num])
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Program) flip() bool {
+	changed := false
+	for i := 0; i+1 < len(p.code); i++ {
+		q, next := p.code[i], p.code[i+1]
+		if (q.op == OpIf || q.op == OpIfFalse) && next.op == OpGoto && p.follows(i+1, q.result.num) {
+			if q.op == OpIf {
+				q.op = OpIfFalse
+			} else {
+				q.op = OpIf
+			}
+			q.result = next.result
+			p.code[i] = q
+			p.delete(i + 1)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Program) jumpsToNext() bool {
+	changed := false
+	for i := 0; i < len(p.code); i++ {
+		if isJump(p.code[i].op) && p.follows(i, p.code[i].result.num) {
+			p.delete(i)
+			i--
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Program) unusedLabels() bool {
+	used := map[int]bool{}
+	for _, q := range p.code {
+		if isJump(q.op) {
+			used[q.result.num] = true
+		}
+	}
+	changed := false
+	for i := 0; i < len(p.code); i++ {
+		if q := p.code[i]; q.op == OpLabel && !used[q.result.num] {
+			p.delete(i)
+			i--
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Program) delete(i int) {
+	p.code = append(p.code[:i], p.code[i+1:]...)
+}
+
+// renumber names the labels L1, L2, ... in the order they appear.
+func (p *Program) renumber() {
+	number := map[int]int{}
+	for _, q := range p.code {
+		if q.op == OpLabel {
+			number[q.result.num] = len(number) + 1
+		}
+	}
+	for i, q := range p.code {
+		if q.op == OpLabel || isJump(q.op) {
+			p.code[i].result = LabelOf(number[q.result.num])
+		}
+	}
+}
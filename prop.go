@@ -0,0 +1,299 @@
+package main
+
+// propagate runs global constant and copy propagation over g until
+// nothing changes, Section 9.1 of the Dragon Book:
+//
+//   - a use of x whose reaching definitions all are x = c, for a constant
+//     c of the type of x, reads c instead,
+//   - a quad whose operands have all become constants is evaluated,
+//   - a use of x where the copy x = y is available, made on every path to
+//     it with neither x nor y assigned since, reads y instead,
+//   - an assignment to a temp that is not live is deleted.
+//
+// Every variable has a definition on entry, so a constant is not
+// propagated along a path on which the variable is never assigned.
+// Blocks that cannot be reached are left alone: nothing flows into them,
+// so every copy would seem available there.
+func (g *CFG) propagate() {
+	for changed := true; changed; {
+		changed = g.propagateConstants() || g.propagateCopies() || g.deadTemps()
+	}
+}
+
+// values returns the operands of q that it reads as values and that may
+// be replaced by an equal one. The array of a load or store and the
+// variable whose address is taken are not among them.
+func (q *Quad) values() []*Operand {
+	var slots []*Operand
+	switch q.op {
+	case OpCopy, OpUnary, OpCast, OpDeref, OpParam:
+		slots = []*Operand{&q.arg1}
+	case OpBinary, OpStore, OpIf, OpIfFalse:
+		slots = []*Operand{&q.arg1, &q.arg2}
+	case OpLoad:
+		slots = []*Operand{&q.arg2}
+	case OpSetDeref:
+		slots = []*Operand{&q.arg1, &q.result}
+	}
+	var names []*Operand
+	for _, o := range slots {
+		if o.isName() {
+			names = append(names, o)
+		}
+	}
+	return names
+}
+
+func (g *CFG) propagateConstants() bool {
+	defs, f := g.reachingDefinitions(true)
+	of := map[Operand][]int{}
+	made := map[*Block]map[int][]int{}
+	for d, def := range defs {
+		of[def.x] = append(of[def.x], d)
+		if made[def.b] == nil {
+			made[def.b] = map[int][]int{}
+		}
+		made[def.b][def.i] = append(made[def.b][def.i], d)
+	}
+	// constant is the value of x if every definition of x in reach
+	// assigns it the same constant.
+	constant := func(x Operand, reach Bits) (Operand, bool) {
+		var c Operand
+		for _, d := range of[x] {
+			if !reach.has(d) {
+				continue
+			} else if defs[d].ambiguous || defs[d].i < 0 {
+				return Operand{}, false
+			}
+			q := defs[d].b.code[defs[d].i]
+			if q.op != OpCopy || q.arg1.kind != ConstOperand || q.arg1.typ != x.typ ||
+				c.kind != NoOperand && q.arg1 != c {
+				return Operand{}, false
+			}
+			c = q.arg1
+		}
+		return c, c.kind != NoOperand
+	}
+
+	reachable := g.reachable()
+	changed := false
+	for _, b := range g.blocks {
+		if !reachable[b] {
+			continue
+		}
+		reach := f.in[b].copy()
+		for i := range b.code {
+			q := &b.code[i]
+			for _, o := range q.values() {
+				if c, ok := constant(*o, reach); ok {
+					*o = c
+					changed = true
+				}
+			}
+			if c, ok := evaluate(*q); ok {
+				*q = Quad{op: OpCopy, arg1: c, result: q.result}
+				changed = true
+			}
+			for _, d := range made[b][i] {
+				if !defs[d].ambiguous {
+					for _, e := range of[defs[d].x] {
+						reach.remove(e)
+					}
+				}
+				reach.add(d)
+			}
+		}
+	}
+	return changed
+}
+
+// evaluate computes q when its operands are constants. Only arithmetic
+// done in the type of its result is evaluated, so that no implicit
+// conversion is lost, and a division by zero is left to run time.
+func evaluate(q Quad) (Operand, bool) {
+	switch q.op {
+	case OpBinary:
+		a, b := q.arg1, q.arg2
+		if a.kind != ConstOperand || b.kind != ConstOperand ||
+			a.typ != q.result.typ || b.typ != q.result.typ || !IsNumbericType(q.result.typ) {
+			return Operand{}, false
+		}
+		c1, c2 := NewConstant(a.val, a.typ), NewConstant(b.val, b.typ)
+		switch op := q.tok.Tag(); op {
+		case '/':
+			if isValue(c2, 0) {
+				return Operand{}, false
+			}
+			fallthrough
+		case '+', '-', '*':
+			return operand(binary(op, q.result.typ, c1, c2)), true
+		}
+	case OpUnary:
+		a := q.arg1
+		if a.kind != ConstOperand || a.typ != q.result.typ || !IsNumbericType(a.typ) || q.tok.Tag() != MINUS {
+			return Operand{}, false
+		}
+		i, r := value(NewConstant(a.val, a.typ))
+		return operand(constant(a.typ, -i, -r)), true
+	case OpCast:
+		a, t := q.arg1, q.arg2.typ
+		if a.kind != ConstOperand || !IsNumbericType(a.typ) || !IsNumbericType(t) {
+			return Operand{}, false
+		}
+		i, r := value(NewConstant(a.val, a.typ))
+		return operand(constant(t, i, r)), true
+	}
+	return Operand{}, false
+}
+
+// copyKey is the copy x = y.
+type copyKey struct{ x, y Operand }
+
+// availableCopies numbers the copies x = y between names of one type and
+// computes which of them are available at the start and the end of every
+// block. Assigning x or y kills the copy, and so does a store through a
+// pointer when the address of x or y is taken.
+func (g *CFG) availableCopies() ([]copyKey, Flow) {
+	memory := map[Operand]bool{}
+	for _, v := range g.addressTaken() {
+		memory[v] = true
+	}
+	var copies []copyKey
+	number := map[copyKey]int{}
+	for _, b := range g.blocks {
+		for _, q := range b.code {
+			if c, ok := copyOf(q); ok {
+				if _, seen := number[c]; !seen {
+					number[c] = len(copies)
+					copies = append(copies, c)
+				}
+			}
+		}
+	}
+
+	gen, kill := map[*Block]Bits{}, map[*Block]Bits{}
+	for _, b := range g.blocks {
+		gen[b], kill[b] = NewBits(len(copies)), NewBits(len(copies))
+		for _, q := range b.code {
+			for _, n := range killedCopies(q, copies, memory) {
+				gen[b].remove(n)
+				kill[b].add(n)
+			}
+			if c, ok := copyOf(q); ok {
+				gen[b].add(number[c])
+				kill[b].remove(number[c])
+			}
+		}
+	}
+	return copies, g.solve(Analysis{
+		dir:      Forward,
+		meet:     Bits.intersect,
+		top:      full(len(copies)),
+		boundary: NewBits(len(copies)),
+		transfer: genKill(gen, kill),
+	})
+}
+
+// copyOf returns the copy q makes, if it is a copy that can be
+// propagated.
+func copyOf(q Quad) (copyKey, bool) {
+	if q.op != OpCopy || !q.arg1.isName() || q.arg1 == q.result || q.arg1.typ != q.result.typ {
+		return copyKey{}, false
+	}
+	return copyKey{q.result, q.arg1}, true
+}
+
+func killedCopies(q Quad, copies []copyKey, memory map[Operand]bool) []int {
+	x, assigns := q.defines()
+	var list []int
+	for n, c := range copies {
+		if assigns && (c.x == x || c.y == x) || q.op == OpSetDeref && (memory[c.x] || memory[c.y]) {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+func (g *CFG) propagateCopies() bool {
+	copies, f := g.availableCopies()
+	memory := map[Operand]bool{}
+	for _, v := range g.addressTaken() {
+		memory[v] = true
+	}
+	reachable := g.reachable()
+	changed := false
+	for _, b := range g.blocks {
+		if !reachable[b] {
+			continue
+		}
+		avail := f.in[b].copy()
+		// copied reports whether a copy into y is available, so that y
+		// may itself be replaced; it is then left for the next pass.
+		copied := func(y Operand) bool {
+			for _, n := range avail.elems() {
+				if copies[n].x == y {
+					return true
+				}
+			}
+			return false
+		}
+		for i := range b.code {
+			q := &b.code[i]
+			for _, o := range q.values() {
+				for _, n := range avail.elems() {
+					if copies[n].x == *o && !copied(copies[n].y) {
+						*o = copies[n].y
+						changed = true
+						break
+					}
+				}
+			}
+			for _, n := range killedCopies(*q, copies, memory) {
+				avail.remove(n)
+			}
+			if c, ok := copyOf(*q); ok {
+				for n := range copies {
+					if copies[n] == c {
+						avail.add(n)
+					}
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// deadTemps deletes the assignments to temps that are not live after
+// them. Calls are kept for their effect.
+func (g *CFG) deadTemps() bool {
+	names, f := g.liveVariables()
+	memory := g.addressTaken()
+	number := map[Operand]int{}
+	for n, o := range names {
+		number[o] = n
+	}
+	changed := false
+	for _, b := range g.blocks {
+		live := f.out[b].copy()
+		for i := len(b.code) - 1; i >= 0; i-- {
+			q := b.code[i]
+			x, assigns := q.defines()
+			if assigns && x.kind == TempOperand && q.op != OpCall && !live.has(number[x]) {
+				b.code = append(b.code[:i], b.code[i+1:]...)
+				changed = true
+				continue
+			}
+			if assigns {
+				live.remove(number[x])
+			}
+			reads := q.uses()
+			if q.op == OpDeref {
+				reads = append(reads, memory...)
+			}
+			for _, o := range reads {
+				live.add(number[o])
+			}
+		}
+	}
+	return changed
+}
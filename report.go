@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// layout lists the storage of every variable and the size of the frame.
+func (p *Parser) layout(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "name\tdepth\toffset\twidth\ttype")
+	for _, sym := range p.symbols {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", sym.name, sym.depth, sym.offset, sym.typ.Width(), sym.typ)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "frame %d\n", p.frame)
+}
+
+// xref lists the variables of every scope with their type and offset,
+// and the line:column positions where they are declared, read and
+// written.
+func (p *Parser) xref(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	var scope *Env
+	for _, sym := range p.symbols {
+		if sym.scope != scope {
+			scope = sym.scope
+			fmt.Fprintf(tw, "scope %s depth %d\n", scope.pos, scope.depth)
+			fmt.Fprintln(tw, "\tname\ttype\toffset\tdeclared\tread\twritten")
+		}
+		fmt.Fprintf(tw, "\t%s\t%s\t%d\t%s\t%s\t%s\n", sym.name, sym.typ, sym.offset, sym.pos, positions(sym.reads), positions(sym.writes))
+	}
+	tw.Flush()
+}
+
+func positions(list []Pos) string {
+	if len(list) == 0 {
+		return "-"
+	}
+	s := make([]string, len(list))
+	for i, pos := range list {
+		s[i] = pos.String()
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,298 @@
+package main
+
+// lattice is the value SCCP knows for a name: nothing yet, one constant,
+// or more than one value.
+type lattice struct {
+	level int
+	c     Operand
+}
+
+const (
+	latticeTop = iota
+	latticeConst
+	latticeBottom
+)
+
+var bottom = lattice{level: latticeBottom}
+
+func (a lattice) meet(b lattice) lattice {
+	switch {
+	case a.level == latticeTop:
+		return b
+	case b.level == latticeTop:
+		return a
+	case a.level == latticeConst && b.level == latticeConst && a.c == b.c:
+		return a
+	}
+	return bottom
+}
+
+// edge is the flow from one block to another.
+type edge struct{ from, to *Block }
+
+// site is a phi or quad i of block b that reads a name.
+type site struct {
+	b   *Block
+	i   int
+	phi *Phi
+}
+
+// SCCP is the sparse conditional constant propagation of Wegman and
+// Zadeck, run on the SSA form. It assumes every name undefined and every
+// edge not executable, and follows two worklists: edges that have become
+// executable, whose target is evaluated, and names whose value has
+// changed, whose uses are evaluated again. A conditional jump whose test
+// is a constant only makes one of its edges executable, so the values on
+// the other side never reach a phi, and what is never reached is dead.
+type SCCP struct {
+	s          *SSA
+	value      map[Operand]lattice
+	executable map[edge]bool
+	visited    map[*Block]bool
+	uses       map[Operand][]site
+	edges      []edge
+	names      []Operand
+}
+
+func NewSCCP(s *SSA) *SCCP {
+	c := &SCCP{
+		s:          s,
+		value:      map[Operand]lattice{},
+		executable: map[edge]bool{},
+		visited:    map[*Block]bool{},
+		uses:       map[Operand][]site{},
+	}
+	for _, b := range s.order {
+		for _, phi := range s.phis[b] {
+			for _, a := range phi.args {
+				c.uses[a] = append(c.uses[a], site{b: b, phi: phi})
+			}
+		}
+		for i, q := range s.code[b] {
+			for _, o := range q.values() {
+				c.uses[*o] = append(c.uses[*o], site{b: b, i: i})
+			}
+		}
+	}
+
+	entry := s.g.entry
+	c.visited[entry] = true
+	c.edges = append(c.edges, edge{entry, s.g.blocks[1]})
+	for len(c.edges) > 0 || len(c.names) > 0 {
+		if len(c.edges) > 0 {
+			e := c.edges[0]
+			c.edges = c.edges[1:]
+			if c.executable[e] {
+				continue
+			}
+			c.executable[e] = true
+			for _, phi := range s.phis[e.to] {
+				c.phi(e.to, phi)
+			}
+			if !c.visited[e.to] {
+				c.visited[e.to] = true
+				c.block(e.to)
+			}
+			continue
+		}
+		x := c.names[0]
+		c.names = c.names[1:]
+		for _, u := range c.uses[x] {
+			if !c.visited[u.b] {
+				continue
+			} else if u.phi != nil {
+				c.phi(u.b, u.phi)
+			} else {
+				c.quad(u.b, u.i)
+			}
+		}
+	}
+	return c
+}
+
+// of is the value of the operand o: a constant is itself, and a name
+// that is not renamed, or read before it is assigned, may be anything.
+func (c *SCCP) of(o Operand) lattice {
+	switch {
+	case o.kind == ConstOperand:
+		return lattice{level: latticeConst, c: o}
+	case o.ver == 0:
+		return bottom
+	}
+	return c.value[o]
+}
+
+func (c *SCCP) set(x Operand, v lattice) {
+	old := c.value[x]
+	if v = old.meet(v); v != old {
+		c.value[x] = v
+		c.names = append(c.names, x)
+	}
+}
+
+func (c *SCCP) flow(from, to *Block) {
+	c.edges = append(c.edges, edge{from, to})
+}
+
+func (c *SCCP) block(b *Block) {
+	for i := range b.code {
+		c.quad(b, i)
+	}
+	if b == c.s.g.exit {
+		return
+	}
+	if n := len(b.code); n == 0 || !isJump(b.code[n-1].op) {
+		c.flow(b, c.s.g.blocks[b.id+1])
+	}
+}
+
+func (c *SCCP) phi(b *Block, phi *Phi) {
+	v := lattice{}
+	for j, p := range b.pred {
+		if c.executable[edge{p, b}] {
+			v = v.meet(c.of(phi.args[j]))
+		}
+	}
+	c.set(phi.x, v)
+}
+
+func (c *SCCP) quad(b *Block, i int) {
+	q := c.s.code[b][i]
+	switch q.op {
+	case OpGoto:
+		c.flow(b, c.target(b, q))
+		return
+	case OpIf, OpIfFalse:
+		t, ok := c.test(q)
+		if !ok {
+			if c.of(q.arg1).level == latticeBottom || q.tok != nil && c.of(q.arg2).level == latticeBottom {
+				c.flow(b, c.target(b, q))
+				c.flow(b, c.s.g.blocks[b.id+1])
+			}
+		} else if t == (q.op == OpIf) {
+			c.flow(b, c.target(b, q))
+		} else {
+			c.flow(b, c.s.g.blocks[b.id+1])
+		}
+		return
+	}
+	x, ok := q.defines()
+	if !ok || x.ver == 0 {
+		return
+	}
+	switch q.op {
+	case OpCopy, OpBinary, OpUnary, OpCast:
+		v, ok := c.constants(&q)
+		if !ok {
+			c.set(x, v)
+			return
+		}
+		if q.op == OpCopy && q.arg1.typ == x.typ {
+			c.set(x, lattice{level: latticeConst, c: q.arg1})
+			return
+		} else if q.op == OpCopy {
+			// An implicit conversion.
+			q = Quad{op: OpCast, arg1: q.arg1, arg2: TypeOf(x.typ), result: x}
+		}
+		if k, ok := evaluate(q); ok {
+			c.set(x, lattice{level: latticeConst, c: k})
+			return
+		}
+	}
+	c.set(x, bottom)
+}
+
+// constants replaces the operands of q by their values. When one of them
+// is not a constant it returns the value of the result instead: bottom
+// if an operand may be anything, top if one is not known yet.
+func (c *SCCP) constants(q *Quad) (lattice, bool) {
+	v := lattice{level: latticeConst}
+	for _, o := range q.values() {
+		switch w := c.of(*o); w.level {
+		case latticeConst:
+			*o = w.c
+		case latticeBottom:
+			return bottom, false
+		default:
+			v = lattice{}
+		}
+	}
+	return v, v.level == latticeConst
+}
+
+// test evaluates the test of a conditional jump if its operands are
+// constants.
+func (c *SCCP) test(q Quad) (bool, bool) {
+	if _, ok := c.constants(&q); !ok {
+		return false, false
+	}
+	var x Node = NewConstant(q.arg1.val, q.arg1.typ)
+	if q.tok != nil {
+		x = Folder{}.rel(NewRel(q.tok, x, NewConstant(q.arg2.val, q.arg2.typ)))
+	}
+	return truth(x)
+}
+
+// target is the block that the jump q at the end of b goes to.
+func (c *SCCP) target(b *Block, q Quad) *Block {
+	for _, s := range b.succ {
+		for _, l := range s.labels {
+			if l == q.result.num {
+				return s
+			}
+		}
+	}
+	panic("no block for " + q.result.String())
+}
+
+// sccp rewrites the code of the CFG with what SCCP has found: names that
+// are constants are replaced by their values, a conditional jump that
+// goes one way only becomes a goto or disappears, and the blocks that are
+// never executed are deleted. The assignments to temps that are left
+// unused are deleted too.
+func (g *CFG) sccp() *Program {
+	s := NewSSA(g)
+	c := NewSCCP(s)
+	blocks := []*Block{g.entry}
+	for _, b := range g.blocks[1 : len(g.blocks)-1] {
+		if !c.visited[b] {
+			continue
+		}
+		var code []Quad
+		for i, q := range b.code {
+			renamed := s.code[b][i]
+			slots, versions := q.values(), renamed.values()
+			for k := range slots {
+				if v := c.of(*versions[k]); v.level == latticeConst {
+					*slots[k] = v.c
+				}
+			}
+			if x, ok := renamed.defines(); ok && q.op != OpCall && x.ver > 0 {
+				if v := c.of(x); v.level == latticeConst {
+					q = Quad{op: OpCopy, arg1: v.c, result: q.result}
+				}
+			} else if k, ok := evaluate(q); ok {
+				// A variable in memory computed from constants.
+				q = Quad{op: OpCopy, arg1: k, result: q.result}
+			}
+			if q.op == OpIf || q.op == OpIfFalse {
+				jumps := c.executable[edge{b, c.target(b, q)}]
+				falls := c.executable[edge{b, g.blocks[b.id+1]}]
+				if jumps && !falls {
+					q = Quad{op: OpGoto, result: q.result}
+				} else if falls && !jumps {
+					continue
+				}
+			}
+			code = append(code, q)
+		}
+		b.code = code
+		blocks = append(blocks, b)
+	}
+	g.blocks = append(blocks, g.exit)
+
+	h := NewCFG(g.program())
+	for h.deadTemps() {
+	}
+	return h.program()
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SSA is the static single assignment form of a CFG, after Cytron et al.:
+// every name is assigned once, and where different definitions of a name
+// meet at the start of a block a phi function picks the one of the
+// predecessor the block was entered from. Dominators are computed with
+// the iterative algorithm of Cooper, Harvey and Kennedy, phi functions
+// are placed on the iterated dominance frontiers of the definitions of
+// the names that are live into some block, and the names are then
+// renamed in a walk of the dominator tree.
+//
+// The quads of a block keep their places: code[b][i] is quad i of b with
+// its names renamed. A definition makes a new version of its name, i.1,
+// i.2, ... for the variable i and t1.1, t1.2, ... for the temp t1, and a
+// name used without a version has the value it has on entry. The
+// versions of the variables that share a name in different scopes are
+// numbered together, so they stay apart. Variables whose address is
+// taken may change through pointers and are not renamed.
+type SSA struct {
+	g        *CFG
+	order    []*Block // the blocks reachable from the entry, in reverse postorder
+	idom     map[*Block]*Block
+	children map[*Block][]*Block // in the dominator tree
+	frontier map[*Block][]*Block
+	phis     map[*Block][]*Phi
+	code     map[*Block][]Quad
+	renamed  map[Operand]bool
+	count    map[string]int // versions made of each name
+}
+
+// Phi is x = phi(args) at the start of a block, with one argument for
+// each predecessor of the block, in order.
+type Phi struct {
+	base Operand
+	x    Operand
+	args []Operand
+}
+
+func NewSSA(g *CFG) *SSA {
+	s := &SSA{
+		g:        g,
+		idom:     map[*Block]*Block{},
+		children: map[*Block][]*Block{},
+		frontier: map[*Block][]*Block{},
+		phis:     map[*Block][]*Phi{},
+		code:     map[*Block][]Quad{},
+		renamed:  map[Operand]bool{},
+		count:    map[string]int{},
+	}
+	s.postorder(g.entry, map[*Block]bool{})
+	for i, j := 0, len(s.order)-1; i < j; i, j = i+1, j-1 {
+		s.order[i], s.order[j] = s.order[j], s.order[i]
+	}
+	s.dominators()
+	s.frontiers()
+	s.placePhis()
+	s.rename(g.entry, map[Operand][]Operand{})
+	return s
+}
+
+func (s *SSA) postorder(b *Block, seen map[*Block]bool) {
+	seen[b] = true
+	for _, c := range b.succ {
+		if !seen[c] {
+			s.postorder(c, seen)
+		}
+	}
+	s.order = append(s.order, b)
+}
+
+// dominators computes the immediate dominator of every reachable block.
+// Each block starts with the meet of the dominators of its processed
+// predecessors, found by walking up from both in postorder numbers, and
+// the blocks are revisited in reverse postorder until nothing changes.
+func (s *SSA) dominators() {
+	number := map[*Block]int{}
+	for i, b := range s.order {
+		number[b] = len(s.order) - i
+	}
+	intersect := func(a, b *Block) *Block {
+		for a != b {
+			for number[a] < number[b] {
+				a = s.idom[a]
+			}
+			for number[b] < number[a] {
+				b = s.idom[b]
+			}
+		}
+		return a
+	}
+	entry := s.g.entry
+	s.idom[entry] = entry
+	for changed := true; changed; {
+		changed = false
+		for _, b := range s.order[1:] {
+			var d *Block
+			for _, p := range b.pred {
+				if s.idom[p] == nil {
+					continue
+				} else if d == nil {
+					d = p
+				} else {
+					d = intersect(p, d)
+				}
+			}
+			if s.idom[b] != d {
+				s.idom[b] = d
+				changed = true
+			}
+		}
+	}
+	for _, b := range s.order[1:] {
+		s.children[s.idom[b]] = append(s.children[s.idom[b]], b)
+	}
+}
+
+// frontiers computes the dominance frontier of every reachable block: a
+// join point is in the frontier of each block on the way up the
+// dominator tree from its predecessors to its immediate dominator.
+func (s *SSA) frontiers() {
+	for _, b := range s.order {
+		if len(b.pred) < 2 {
+			continue
+		}
+		for _, p := range b.pred {
+			if s.idom[p] == nil {
+				continue
+			}
+			for r := p; r != s.idom[b]; r = s.idom[r] {
+				if !containsBlock(s.frontier[r], b) {
+					s.frontier[r] = append(s.frontier[r], b)
+				}
+			}
+		}
+	}
+}
+
+func containsBlock(list []*Block, b *Block) bool {
+	for _, c := range list {
+		if c == b {
+			return true
+		}
+	}
+	return false
+}
+
+// placePhis chooses the names to rename and places their phi functions.
+// Only a name that some block reads before assigning it needs one.
+func (s *SSA) placePhis() {
+	memory := map[Operand]bool{}
+	for _, v := range s.g.addressTaken() {
+		memory[v] = true
+	}
+	var names []Operand
+	sites := map[Operand][]*Block{}
+	global := map[Operand]bool{}
+	for _, b := range s.order {
+		assigned := map[Operand]bool{}
+		for _, q := range b.code {
+			for _, o := range q.uses() {
+				if !assigned[o] {
+					global[o] = true
+				}
+			}
+			if x, ok := q.defines(); ok && !memory[x] {
+				if !s.renamed[x] {
+					s.renamed[x] = true
+					names = append(names, x)
+				}
+				if !containsBlock(sites[x], b) {
+					sites[x] = append(sites[x], b)
+				}
+				assigned[x] = true
+			}
+		}
+	}
+	for _, x := range names {
+		if !global[x] {
+			continue
+		}
+		work := append([]*Block(nil), sites[x]...)
+		placed := map[*Block]bool{}
+		for len(work) > 0 {
+			b := work[len(work)-1]
+			work = work[:len(work)-1]
+			for _, f := range s.frontier[b] {
+				if placed[f] {
+					continue
+				}
+				placed[f] = true
+				s.phis[f] = append(s.phis[f], &Phi{base: x, x: x, args: make([]Operand, len(f.pred))})
+				if !containsBlock(sites[x], f) {
+					work = append(work, f)
+				}
+			}
+		}
+	}
+}
+
+// rename renames the names of b and of the blocks it dominates. stack
+// holds the versions of each name that are visible, the last on top.
+func (s *SSA) rename(b *Block, stack map[Operand][]Operand) {
+	var pushed []Operand
+	define := func(x Operand) Operand {
+		name := x.String()
+		s.count[name]++
+		v := x
+		v.ver = s.count[name]
+		stack[x] = append(stack[x], v)
+		pushed = append(pushed, x)
+		return v
+	}
+	current := func(x Operand) Operand {
+		if versions := stack[x]; len(versions) > 0 {
+			return versions[len(versions)-1]
+		}
+		return x
+	}
+
+	for _, phi := range s.phis[b] {
+		phi.x = define(phi.base)
+	}
+	code := append([]Quad(nil), b.code...)
+	for i := range code {
+		q := &code[i]
+		for _, o := range q.values() {
+			if s.renamed[*o] {
+				*o = current(*o)
+			}
+		}
+		if x, ok := q.defines(); ok && s.renamed[x] {
+			q.result = define(x)
+		}
+	}
+	s.code[b] = code
+	for _, c := range b.succ {
+		for j, p := range c.pred {
+			if p != b {
+				continue
+			}
+			for _, phi := range s.phis[c] {
+				phi.args[j] = current(phi.base)
+			}
+		}
+	}
+	for _, c := range s.children[b] {
+		s.rename(c, stack)
+	}
+	for _, x := range pushed {
+		stack[x] = stack[x][:len(stack[x])-1]
+	}
+}
+
+// foldCopies deletes the copies between renamed names of one type and
+// makes their uses read the copied name. The versions of a name then
+// overlap, so that the copies that take the code out of SSA form must be
+// placed with care. The quads no longer keep their places.
+func (s *SSA) foldCopies() {
+	subst := map[Operand]Operand{}
+	for _, b := range s.order {
+		code := s.code[b][:0]
+		for _, q := range s.code[b] {
+			if q.op == OpCopy && q.result.ver > 0 && q.arg1.ver > 0 && q.arg1.typ == q.result.typ {
+				subst[q.result] = q.arg1
+				continue
+			}
+			code = append(code, q)
+		}
+		s.code[b] = code
+	}
+	find := func(x Operand) Operand {
+		for {
+			y, ok := subst[x]
+			if !ok {
+				return x
+			}
+			x = y
+		}
+	}
+	for _, b := range s.order {
+		for _, phi := range s.phis[b] {
+			for j := range phi.args {
+				phi.args[j] = find(phi.args[j])
+			}
+		}
+		for i := range s.code[b] {
+			for _, o := range s.code[b][i].values() {
+				*o = find(*o)
+			}
+		}
+	}
+}
+
+// print writes the blocks in SSA form, with their phi functions:
+//
+//	B2 L3:	pred B1 B6	succ B3 B7
+//		i.2 = phi(i.1, i.3)
+//		iffalse i.2 < 10 goto L4
+//
+// A phi function has no argument for a predecessor that cannot be
+// reached.
+func (s *SSA) print(w io.Writer) {
+	for _, b := range s.g.blocks {
+		if s.idom[b] == nil {
+			continue
+		}
+		s.g.header(w, b)
+		for _, phi := range s.phis[b] {
+			var args []string
+			for j, a := range phi.args {
+				if s.idom[b.pred[j]] != nil {
+					args = append(args, a.String())
+				}
+			}
+			fmt.Fprintf(w, "\t%s = phi(%s)\n", phi.x, strings.Join(args, ", "))
+		}
+		for _, q := range s.code[b] {
+			fmt.Fprintf(w, "\t%s\n", q)
+		}
+	}
+}
+
+// program takes s out of SSA form. A phi function becomes a copy at the
+// end of each predecessor, and the copies of one predecessor happen at
+// once, so they are ordered by sequence. When the predecessor has other
+// successors the copies would be seen on their paths too, and with
+// folded copies they may overwrite a value still live there, the lost
+// copy problem. Such an edge is split by a block of its own: one that
+// the predecessor falls through to is laid out right after it, and one
+// that it jumps to at the end of the program. Blocks that cannot be
+// reached are dropped. Finally the versions of each name are coalesced
+// back into the name where they do not interfere, and the split blocks
+// left without copies are bypassed.
+func (s *SSA) program() *Program {
+	g := s.g
+	code := map[*Block][]Quad{}
+	for _, b := range s.order {
+		code[b] = append([]Quad(nil), s.code[b]...)
+	}
+	after := map[*Block][]Quad{}
+	var tail []Quad
+	split := map[int]bool{}
+	for _, b := range g.blocks {
+		if len(s.phis[b]) == 0 || s.idom[b] == nil {
+			continue
+		}
+		for j, p := range b.pred {
+			if s.idom[p] == nil {
+				continue
+			}
+			var moves []copyKey
+			for _, phi := range s.phis[b] {
+				if phi.args[j] != phi.x {
+					moves = append(moves, copyKey{phi.x, phi.args[j]})
+				}
+			}
+			copies := sequence(moves)
+			if len(copies) == 0 {
+				continue
+			}
+			c := code[p]
+			var last Quad
+			if len(c) > 0 {
+				last = c[len(c)-1]
+			}
+			switch {
+			case len(p.succ) == 1 && last.op == OpGoto:
+				code[p] = append(append(c[:len(c)-1:len(c)-1], copies...), last)
+			case len(p.succ) == 1 && (last.op == OpIf || last.op == OpIfFalse):
+				// Both ways lead to b.
+				code[p] = append(c[:len(c)-1], copies...)
+			case len(p.succ) == 1:
+				code[p] = append(c, copies...)
+			case isJump(last.op) && containsInt(b.labels, last.result.num):
+				l := newLabel()
+				c[len(c)-1].result = LabelOf(l)
+				split[l] = true
+				tail = append(tail, Quad{op: OpLabel, result: LabelOf(l)})
+				tail = append(tail, copies...)
+				tail = append(tail, Quad{op: OpGoto, result: LabelOf(b.labels[0])})
+			default:
+				after[p] = append(after[p], copies...)
+			}
+		}
+	}
+
+	p := &Program{}
+	for _, b := range g.blocks[:len(g.blocks)-1] {
+		if s.idom[b] == nil {
+			continue
+		}
+		for _, l := range b.labels {
+			p.Label(l)
+		}
+		p.code = append(p.code, code[b]...)
+		p.code = append(p.code, after[b]...)
+	}
+	end := 0
+	if len(tail) > 0 {
+		end = newLabel()
+		p.Goto(end)
+		p.code = append(p.code, tail...)
+		p.Label(end)
+	}
+	for _, l := range g.exit.labels {
+		p.Label(l)
+	}
+	h := NewCFG(p)
+	h.coalesce()
+	p = h.program()
+	p.bypass(split, end)
+	return p
+}
+
+// bypass deletes the split blocks, labeled by split, that are left with
+// nothing but their jump, and makes the jumps to them go where they go.
+// When no split block is left, the jump to end around them goes too.
+func (p *Program) bypass(split map[int]bool, end int) {
+	to := map[int]Operand{}
+	for i := 0; i+1 < len(p.code); i++ {
+		if q := p.code[i]; q.op == OpLabel && split[q.result.num] && p.code[i+1].op == OpGoto {
+			to[q.result.num] = p.code[i+1].result
+		}
+	}
+	code := p.code[:0]
+	for i := 0; i < len(p.code); i++ {
+		q := p.code[i]
+		if _, ok := to[q.result.num]; ok && q.op == OpLabel {
+			i++
+			continue
+		} else if l, ok := to[q.result.num]; ok && isJump(q.op) {
+			q.result = l
+		}
+		code = append(code, q)
+	}
+	p.code = code
+	for i := 0; i+1 < len(p.code); i++ {
+		if q := p.code[i]; q.op == OpGoto && q.result.num == end && p.code[i+1].op == OpLabel && p.code[i+1].result.num == end {
+			p.code = append(p.code[:i], p.code[i+2:]...)
+			break
+		}
+	}
+}
+
+func containsInt(list []int, i int) bool {
+	for _, j := range list {
+		if j == i {
+			return true
+		}
+	}
+	return false
+}
+
+// sequence orders the parallel copies x = y, which read all their sources
+// before they write, into quads that run one after the other. A copy is
+// made once no other copy still reads what it overwrites. When only
+// cycles are left, as in the swap a, b = b, a, one value is saved in a
+// new temp first.
+func sequence(moves []copyKey) []Quad {
+	var code []Quad
+	for len(moves) > 0 {
+		ready := -1
+		for i, m := range moves {
+			read := false
+			for _, n := range moves {
+				if n.y == m.x {
+					read = true
+				}
+			}
+			if !read {
+				ready = i
+				break
+			}
+		}
+		if ready < 0 {
+			x := moves[0].x
+			t := operand(NewTemp(x.typ))
+			code = append(code, Quad{op: OpCopy, arg1: x, result: t})
+			for i := range moves {
+				if moves[i].y == x {
+					moves[i].y = t
+				}
+			}
+			continue
+		}
+		m := moves[ready]
+		code = append(code, Quad{op: OpCopy, arg1: m.y, result: m.x})
+		moves = append(moves[:ready], moves[ready+1:]...)
+	}
+	return code
+}
+
+// coalesce gives the versions of each name back the name itself, after
+// SSA form, where they do not interfere: where none of them is assigned
+// while another one is live, unless the assignment copies the other.
+// Versions that interfere become new temps, so that they cannot be
+// confused with another name. Copies of a name into itself are deleted.
+func (g *CFG) coalesce() {
+	names, f := g.liveVariables()
+	memory := g.addressTaken()
+	number := map[Operand]int{}
+	for n, o := range names {
+		number[o] = n
+	}
+	interfere := map[copyKey]bool{}
+	for _, b := range g.blocks {
+		live := f.out[b].copy()
+		for i := len(b.code) - 1; i >= 0; i-- {
+			q := b.code[i]
+			if x, ok := q.defines(); ok {
+				for _, n := range live.elems() {
+					if y := names[n]; y != x && !(q.op == OpCopy && q.arg1 == y) {
+						interfere[copyKey{x, y}], interfere[copyKey{y, x}] = true, true
+					}
+				}
+				live.remove(number[x])
+			}
+			reads := q.uses()
+			if q.op == OpDeref {
+				reads = append(reads, memory...)
+			}
+			for _, o := range reads {
+				live.add(number[o])
+			}
+		}
+	}
+
+	var bases []Operand
+	versions := map[Operand][]Operand{}
+	for _, o := range names {
+		if o.ver == 0 {
+			continue
+		}
+		base := o
+		base.ver = 0
+		if versions[base] == nil {
+			bases = append(bases, base)
+		}
+		versions[base] = append(versions[base], o)
+	}
+	rename := map[Operand]Operand{}
+	for _, base := range bases {
+		class := []Operand{base}
+		for _, v := range versions[base] {
+			free := true
+			for _, c := range class {
+				free = free && !interfere[copyKey{v, c}]
+			}
+			if free {
+				class = append(class, v)
+				rename[v] = base
+			} else {
+				rename[v] = operand(NewTemp(v.typ))
+			}
+		}
+	}
+
+	for _, b := range g.blocks {
+		code := b.code[:0]
+		for _, q := range b.code {
+			if _, ok := q.defines(); ok {
+				if y, ok := rename[q.result]; ok {
+					q.result = y
+				}
+			}
+			for _, o := range q.values() {
+				if y, ok := rename[*o]; ok {
+					*o = y
+				}
+			}
+			if q.op == OpCopy && q.arg1 == q.result {
+				continue
+			}
+			code = append(code, q)
+		}
+		b.code = code
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Target is the data model of the machine the code is generated for: the
+// size and the alignment in bytes of every basic type and of pointers,
+// keyed by type lexeme and "pointer". An alignment that is left out
+// defaults to the size.
+//
+// Widths of types, the offsets of variables and all the address
+// arithmetic in the three-address code follow the target.
+type Target struct {
+	Name  string         `json:"name"`
+	Sizes map[string]int `json:"sizes"`
+	Align map[string]int `json:"align"`
+}
+
+// targetTypes are the types every target must describe.
+var targetTypes = []string{"byte", "char", "int", "long", "float", "double", "bool", "pointer"}
+
+var targets = map[string]*Target{
+	// dragon is the model of the book: wide floats and variables packed
+	// without any padding.
+	"dragon": {
+		Name:  "dragon",
+		Sizes: map[string]int{"byte": 1, "char": 1, "int": 4, "long": 8, "float": 8, "double": 8, "bool": 1, "pointer": 8},
+		Align: map[string]int{"byte": 1, "char": 1, "int": 1, "long": 1, "float": 1, "double": 1, "bool": 1, "pointer": 1},
+	},
+	"ilp32": {
+		Name:  "ilp32",
+		Sizes: map[string]int{"byte": 1, "char": 1, "int": 4, "long": 4, "float": 4, "double": 8, "bool": 1, "pointer": 4},
+	},
+	"lp64": {
+		Name:  "lp64",
+		Sizes: map[string]int{"byte": 1, "char": 1, "int": 4, "long": 8, "float": 4, "double": 8, "bool": 1, "pointer": 8},
+	},
+}
+
+var target = targets["dragon"]
+
+// LoadTarget returns the predefined target called name, or reads the
+// target from name if it is a JSON file.
+func LoadTarget(name string) (*Target, error) {
+	if !strings.HasSuffix(name, ".json") {
+		t, ok := targets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown target %s", name)
+		}
+		return t, nil
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var t Target
+	if err := json.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if t.Name == "" {
+		t.Name = name
+	}
+	return &t, t.validate()
+}
+
+// validate makes sure every type has a size and a power of two alignment
+// that divides it, so that the elements of an array stay aligned.
+func (t *Target) validate() error {
+	for _, typ := range targetTypes {
+		size, align := t.Sizes[typ], t.align(typ)
+		if size <= 0 {
+			return fmt.Errorf("target %s: no size for %s", t.Name, typ)
+		} else if align <= 0 || align&(align-1) != 0 || size%align != 0 {
+			return fmt.Errorf("target %s: bad alignment %d for %s of size %d", t.Name, align, typ, size)
+		}
+	}
+	return nil
+}
+
+func (t *Target) size(typ string) int { return t.Sizes[typ] }
+
+func (t *Target) align(typ string) int {
+	if a, ok := t.Align[typ]; ok {
+		return a
+	}
+	return t.Sizes[typ]
+}
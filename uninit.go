@@ -0,0 +1,143 @@
+package main
+
+import "fmt"
+
+// assigned is the set of variables definitely assigned at a point in the
+// program. The point after a break is unreachable; there every variable
+// counts as assigned, which makes all the identity of meet.
+type assigned struct {
+	all  bool
+	vars map[*Symbol]bool
+}
+
+func (a assigned) has(s *Symbol) bool { return a.all || a.vars[s] }
+
+func (a assigned) with(s *Symbol) assigned {
+	if a.has(s) {
+		return a
+	}
+	vars := map[*Symbol]bool{s: true}
+	for v := range a.vars {
+		vars[v] = true
+	}
+	return assigned{vars: vars}
+}
+
+func meet(a, b assigned) assigned {
+	if a.all {
+		return b
+	} else if b.all {
+		return a
+	}
+	vars := map[*Symbol]bool{}
+	for v := range a.vars {
+		if b.vars[v] {
+			vars[v] = true
+		}
+	}
+	return assigned{vars: vars}
+}
+
+// InitChecker is a definite-assignment analysis over the statement tree.
+// It warns when a variable may be read before any assignment to it on
+// some path through if, else, while, do and break. Arrays are tracked as
+// a whole: reading an element is only flagged when no element of the
+// array has been written, and taking the address of a variable counts as
+// assigning it.
+type InitChecker struct {
+	breaks map[Node][]assigned // sets reaching the breaks out of each loop
+	warned map[*Symbol]bool
+}
+
+func NewInitChecker() *InitChecker {
+	return &InitChecker{breaks: map[Node][]assigned{}, warned: map[*Symbol]bool{}}
+}
+
+func (c *InitChecker) stmt(n Node, in assigned) assigned {
+	switch s := n.(type) {
+	case Seq:
+		return c.stmt(s.stmt2, c.stmt(s.stmt1, in))
+	case If:
+		c.stmt(s.stmt, c.expr(s.Line, s.expr, in))
+		return in
+	case Else:
+		in = c.expr(s.Line, s.expr, in)
+		return meet(c.stmt(s.stmt1, in), c.stmt(s.stmt2, in))
+	case *While:
+		c.stmt(s.stmt, c.expr(s.Line, s.expr, in))
+		return in
+	case *Do:
+		out := c.expr(s.Line, s.expr, c.stmt(s.stmt, in))
+		for _, b := range c.breaks[s] {
+			out = meet(out, b)
+		}
+		return out
+	case Break:
+		c.breaks[s.stmt] = append(c.breaks[s.stmt], in)
+		return assigned{all: true}
+	case Set:
+		return c.expr(s.Line, s.expr, in).with(s.id.sym)
+	case SetElem:
+		in = c.expr(s.Line, s.index, in)
+		return c.expr(s.Line, s.expr, in).with(s.array.sym)
+	case SetDeref:
+		return c.expr(s.Line, s.expr, c.expr(s.Line, s.addr, in))
+	case ArrayCopy:
+		in = c.expr(s.Line, s.src, in)
+		if a, ok := s.dst.(Access); ok {
+			in = c.expr(s.Line, a.index, in)
+			return in.with(a.array.sym)
+		}
+		return in.with(s.dst.(Id).sym)
+	case Print:
+		for _, x := range s.args {
+			in = c.expr(s.Line, x, in)
+		}
+		return in
+	}
+	return in
+}
+
+// expr checks the variables read by n in statement l.
+func (c *InitChecker) expr(l Line, n Node, in assigned) assigned {
+	switch x := n.(type) {
+	case Id:
+		c.use(l, x, in)
+	case Access:
+		c.use(l, x.array, in)
+		return c.expr(l, x.index, in)
+	case Arith:
+		return c.expr(l, x.expr2, c.expr(l, x.expr1, in))
+	case Bound:
+		return c.expr(l, x.index, in)
+	case Unary:
+		return c.expr(l, x.expr, in)
+	case Cast:
+		return c.expr(l, x.expr, in)
+	case Deref:
+		return c.expr(l, x.expr, in)
+	case AddrOf:
+		return in.with(x.id.sym)
+	case Call:
+		for _, arg := range x.args {
+			in = c.expr(l, arg, in)
+		}
+	case Rel:
+		return c.expr(l, x.expr2, c.expr(l, x.expr1, in))
+	case OrNode:
+		return c.expr(l, x.expr2, c.expr(l, x.expr1, in))
+	case AndNode:
+		return c.expr(l, x.expr2, c.expr(l, x.expr1, in))
+	case Not:
+		return c.expr(l, x.expr2, in)
+	}
+	return in
+}
+
+func (c *InitChecker) use(l Line, id Id, in assigned) {
+	if in.has(id.sym) || c.warned[id.sym] {
+		return
+	}
+	c.warned[id.sym] = true
+	l.warn(fmt.Sprintf("%s may be used before initialization", id))
+}